@@ -0,0 +1,124 @@
+package untold
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BundleExtension is the extension of bundle file, {environment}.untold, which holds all secret files
+// of the environment instead of environment directory. Each line of the bundle holds the name of secret
+// file, which is the hash of secret's name, followed by colon and envelope of the file written on one line:
+// format version, algorithm and ciphertexts, each prefixed with ID of recipient's key and colon, e.g.
+//
+//	098f6bcd4621d373cade4e832627b4f6: v2 x25519-xsalsa20-poly1305 4fa41f86:EfrKsJo19/VX7EPB...
+//
+// IndexFile, which holds several envelopes, takes a line per envelope; no other file name may repeat.
+// Lines are sorted by file name, so bundle is easy to review and merge, and key rotation or change
+// of recipients shows in its diff.
+const BundleExtension = ".untold"
+
+// ParseBundle parses content of bundle file into files keyed by their names.
+func ParseBundle(content []byte) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	var previous string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, len(content)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		i := strings.Index(text, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected file name and envelope", line)
+		}
+
+		name := strings.TrimSpace(text[:i])
+		if _, ok := files[name]; ok && (name != IndexFile || name != previous) {
+			return nil, fmt.Errorf("line %d: duplicate file %q", line, name)
+		}
+
+		envelope, err := parseBundleEnvelope(text[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if files[name] == nil {
+			files[name] = envelope.Bytes()
+		} else {
+			files[name] = append(append(files[name], indexEntrySeparator...), envelope.Bytes()...)
+		}
+
+		previous = name
+	}
+
+	return files, scanner.Err()
+}
+
+// FormatBundle formats files as content of bundle file. Every file must hold envelopes.
+func FormatBundle(files map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var buffer bytes.Buffer
+	for _, name := range names {
+		for _, content := range bytes.Split(bytes.TrimSpace(files[name]), indexEntrySeparator) {
+			envelope, err := ParseEnvelope(content)
+			if err != nil {
+				return nil, fmt.Errorf("parse %q file: %w", name, err)
+			}
+
+			fmt.Fprintf(&buffer, "%s: v%d %s", name, envelope.Version, envelope.Algorithm)
+			for i := range envelope.Ciphertexts {
+				fmt.Fprintf(&buffer, " %s:%s", envelope.Ciphertexts[i].KeyID, Base64Encode(envelope.Ciphertexts[i].Data))
+			}
+
+			buffer.WriteString("\n")
+		}
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// parseBundleEnvelope parses envelope written on a line of bundle file.
+func parseBundleEnvelope(text string) (Envelope, error) {
+	var envelope Envelope
+
+	fields := strings.Fields(text)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "v") {
+		return envelope, errors.New("expected envelope version and algorithm")
+	}
+
+	version, err := strconv.Atoi(strings.TrimPrefix(fields[0], "v"))
+	if err != nil {
+		return envelope, fmt.Errorf("malformed envelope version %q", fields[0])
+	}
+
+	envelope.Version, envelope.Algorithm = version, fields[1]
+	for _, field := range fields[2:] {
+		i := strings.Index(field, ":")
+		if i < 0 {
+			return envelope, fmt.Errorf("expected key ID and ciphertext, got %q", field)
+		}
+
+		data, err := Base64Decode([]byte(field[i+1:]))
+		if err != nil {
+			return envelope, fmt.Errorf("base64 decode: %w", err)
+		}
+
+		envelope.Ciphertexts = append(envelope.Ciphertexts, Ciphertext{KeyID: field[:i], Data: data})
+	}
+
+	return envelope, nil
+}
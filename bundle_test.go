@@ -0,0 +1,101 @@
+package untold
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFormatBundle(t *testing.T) {
+	files := map[string][]byte{
+		"098f6bcd4621d373cade4e832627b4f6": []byte("untold-secret v2 x25519-xsalsa20-poly1305\n0a0b0c0d ZGF0YQ\n0e0f1011 b3RoZXI"),
+		"0cc175b9c0f1b6a831c399e269772661": []byte("bGVnYWN5"),
+		IndexFile:                          []byte("untold-secret v2 x25519-xsalsa20-poly1305\n0a0b0c0d Zmlyc3Q\n\nuntold-secret v2 x25519-xsalsa20-poly1305\n0a0b0c0d c2Vjb25k"),
+	}
+
+	content, err := FormatBundle(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "098f6bcd4621d373cade4e832627b4f6: v2 x25519-xsalsa20-poly1305 0a0b0c0d:ZGF0YQ 0e0f1011:b3RoZXI\n" +
+		"0cc175b9c0f1b6a831c399e269772661: v0 x25519-xsalsa20-poly1305 :bGVnYWN5\n" +
+		"index: v2 x25519-xsalsa20-poly1305 0a0b0c0d:Zmlyc3Q\n" +
+		"index: v2 x25519-xsalsa20-poly1305 0a0b0c0d:c2Vjb25k\n"
+	if string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, content)
+	}
+
+	parsed, err := ParseBundle(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(parsed, files) {
+		t.Errorf("expected %q, got %q", files, parsed)
+	}
+
+	duplicate := "a: v2 x25519-xsalsa20-poly1305 0a0b0c0d:ZGF0YQ\nb: v2 x25519-xsalsa20-poly1305 0a0b0c0d:ZGF0YQ\na: v2 x25519-xsalsa20-poly1305 0a0b0c0d:ZGF0YQ\n"
+	if _, err := ParseBundle([]byte(duplicate)); err == nil {
+		t.Error("expected error for duplicate file")
+	}
+
+	adjacent := "a: v2 x25519-xsalsa20-poly1305 0a0b0c0d:ZGF0YQ\na: v2 x25519-xsalsa20-poly1305 0a0b0c0d:ZGF0YQ\n"
+	if _, err := ParseBundle([]byte(adjacent)); err == nil {
+		t.Error("expected error for adjacent duplicate file")
+	}
+
+	if _, err := FormatBundle(map[string][]byte{"a": []byte("untold-secret v2")}); err == nil {
+		t.Error("expected error for file not holding envelope")
+	}
+}
+
+func TestLoadFromBundle(t *testing.T) {
+	files := testFiles(t, "test/test.private", "test/test/098f6bcd4621d373cade4e832627b4f6")
+
+	// secret file moved from environment directory to bundle
+	bundle, err := FormatBundle(map[string][]byte{"098f6bcd4621d373cade4e832627b4f6": files["test/test/098f6bcd4621d373cade4e832627b4f6"].Data})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delete(files, "test/test/098f6bcd4621d373cade4e832627b4f6")
+	files["test/test"+BundleExtension] = &fstest.MapFile{Data: bundle}
+
+	v := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey())
+
+	value, err := v.Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "test" {
+		t.Errorf("expected %q, got %q", "test", value)
+	}
+
+	if _, ok, err := v.Lookup("doesnt_exist"); ok || err != nil {
+		t.Errorf("expected secret not to exist, got %v, %v", ok, err)
+	}
+}
+
+func TestLoadBundleFromEmbedFS(t *testing.T) {
+	v := NewVault(testFS, Environment("bundled"), PathPrefix("test"), AllowEmbeddedPrivateKey())
+
+	value, err := v.Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "test" {
+		t.Errorf("expected %q, got %q", "test", value)
+	}
+
+	names, err := v.Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"test"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %q, got %q", expected, names)
+	}
+}
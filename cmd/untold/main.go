@@ -20,6 +20,15 @@ func main() {
 
 	subcommands.Register(vault.NewCreateCommand(), "vault management")
 	subcommands.Register(vault.NewRotateCommand(), "vault management")
+	subcommands.Register(vault.NewRetireCommand(), "vault management")
+	subcommands.Register(vault.NewAddRecipientCommand(), "vault management")
+	subcommands.Register(vault.NewRemoveRecipientCommand(), "vault management")
+	subcommands.Register(vault.NewGenerateKeyCommand(), "vault management")
+	subcommands.Register(vault.NewMigrateNamesCommand(), "vault management")
+	subcommands.Register(vault.NewConvertCommand(), "vault management")
+	subcommands.Register(vault.NewSplitKeyCommand(), "vault management")
+	subcommands.Register(vault.NewCombineKeyCommand(), "vault management")
+	subcommands.Register(untold.NewCheckEmbedCommand(), "vault management")
 
 	subcommands.Register(secret.NewAddCommand(), "secrets")
 	subcommands.Register(secret.NewShowCommand(), "secrets")
@@ -0,0 +1,238 @@
+package untold
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+	urlType             = reflect.TypeOf(url.URL{})
+)
+
+// isContainer reports whether type is a struct which should be traversed
+// instead of being decoded from a single secret.
+func isContainer(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct && t != urlType && !reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// decodeValue decodes value of secret with given name into field according to field's type and tag options.
+// Decoders registered for field's type take precedence over built-in decoding.
+func decodeValue(field reflect.Value, name string, t tag, value string, decoders decoderRegistry) error {
+	raw, err := decodeEncoding(value, t)
+	if err != nil {
+		return err
+	}
+
+	if t.has("json") {
+		return decodeJSON(field, raw)
+	}
+
+	return decodeInto(field, name, raw, decoders)
+}
+
+// decodeJSON unmarshals JSON document into field. Errors point to the part of document which failed.
+func decodeJSON(field reflect.Value, raw []byte) error {
+	pointer := reflect.New(field.Type())
+	if err := json.Unmarshal(raw, pointer.Interface()); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		var syntaxErr *json.SyntaxError
+
+		switch {
+		case errors.As(err, &typeErr) && typeErr.Field != "":
+			return fmt.Errorf("decode JSON at %q: %w", typeErr.Field, err)
+		case errors.As(err, &syntaxErr):
+			return fmt.Errorf("decode JSON at offset %d: %w", syntaxErr.Offset, err)
+		default:
+			return fmt.Errorf("decode JSON: %w", err)
+		}
+	}
+
+	field.Set(pointer.Elem())
+
+	return nil
+}
+
+func decodeEncoding(value string, t tag) ([]byte, error) {
+	encoding, _ := t.get("encoding")
+
+	switch encoding {
+	case "", "raw":
+		return []byte(value), nil
+	case "base64":
+		value = strings.TrimSpace(value)
+		if strings.HasSuffix(value, "=") {
+			return base64.StdEncoding.DecodeString(value)
+		}
+
+		return base64.RawStdEncoding.DecodeString(value)
+	case "hex":
+		return hex.DecodeString(strings.TrimSpace(value))
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
+
+func decodeInto(field reflect.Value, name string, raw []byte, decoders decoderRegistry) error {
+	if decoder, ok := decoders.lookup(field.Type()); ok {
+		result, err := decoder(name, raw)
+		if err != nil {
+			return err
+		}
+
+		return assign(field, result)
+	}
+
+	if field.Kind() == reflect.Ptr {
+		value := reflect.New(field.Type().Elem())
+		if err := decodeInto(value.Elem(), name, raw, decoders); err != nil {
+			return err
+		}
+
+		field.Set(value)
+
+		return nil
+	}
+
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(raw)
+	}
+
+	text := strings.TrimSpace(string(raw))
+
+	switch field.Type() {
+	case urlType:
+		parsed, err := url.Parse(text)
+		if err != nil {
+			return err
+		}
+
+		field.Set(reflect.ValueOf(*parsed))
+
+		return nil
+	case durationType:
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(int64(duration))
+
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(string(raw))
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.Uint8 {
+			return decodeSlice(field, name, raw, decoders)
+		}
+
+		field.SetBytes(raw)
+	case reflect.Map:
+		return decodeMap(field, name, raw, decoders)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+
+		field.SetBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err := strconv.ParseInt(text, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		value, err := strconv.ParseUint(text, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetUint(value)
+	case reflect.Float32, reflect.Float64:
+		value, err := strconv.ParseFloat(text, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetFloat(value)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
+
+// decodeSlice decodes JSON array into slice. Elements are decoded the same way as single secrets.
+func decodeSlice(field reflect.Value, name string, raw []byte, decoders decoderRegistry) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return fmt.Errorf("decode JSON array: %w", err)
+	}
+
+	slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+	for i := range items {
+		if err := decodeElement(slice.Index(i), name, items[i], decoders); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+
+	field.Set(slice)
+
+	return nil
+}
+
+// decodeMap decodes JSON object into map. Keys and values are decoded the same way as single secrets.
+func decodeMap(field reflect.Value, name string, raw []byte, decoders decoderRegistry) error {
+	var items map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return fmt.Errorf("decode JSON object: %w", err)
+	}
+
+	result := reflect.MakeMapWithSize(field.Type(), len(items))
+	for key, item := range items {
+		keyValue := reflect.New(field.Type().Key()).Elem()
+		if err := decodeInto(keyValue, name, []byte(key), decoders); err != nil {
+			return fmt.Errorf("key %q: %w", key, err)
+		}
+
+		elementValue := reflect.New(field.Type().Elem()).Elem()
+		if err := decodeElement(elementValue, name, item, decoders); err != nil {
+			return fmt.Errorf("key %q: %w", key, err)
+		}
+
+		result.SetMapIndex(keyValue, elementValue)
+	}
+
+	field.Set(result)
+
+	return nil
+}
+
+// decodeElement decodes JSON strings as text of a single secret, other JSON values are unmarshalled as is.
+func decodeElement(element reflect.Value, name string, item json.RawMessage, decoders decoderRegistry) error {
+	var text string
+	if err := json.Unmarshal(item, &text); err == nil {
+		return decodeInto(element, name, []byte(text), decoders)
+	}
+
+	return json.Unmarshal(item, element.Addr().Interface())
+}
@@ -0,0 +1,53 @@
+package untold
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// DecoderFunc decodes raw value of secret with given name into a value of registered type.
+type DecoderFunc func(name string, raw []byte) (interface{}, error)
+
+type decoderRegistry map[reflect.Type]DecoderFunc
+
+// lookup finds decoder registered for the type or for the type it points to.
+func (r decoderRegistry) lookup(t reflect.Type) (DecoderFunc, bool) {
+	if decoder, ok := r[t]; ok {
+		return decoder, true
+	}
+
+	if t.Kind() == reflect.Ptr {
+		decoder, ok := r[t.Elem()]
+
+		return decoder, ok
+	}
+
+	return nil, false
+}
+
+// assign sets value returned by decoder to the field, adding or removing one level of indirection if needed.
+func assign(field reflect.Value, result interface{}) error {
+	value := reflect.ValueOf(result)
+	if !value.IsValid() {
+		return nil
+	}
+
+	switch {
+	case value.Type().AssignableTo(field.Type()):
+		field.Set(value)
+	case field.Kind() == reflect.Ptr && value.Type().AssignableTo(field.Type().Elem()):
+		pointer := reflect.New(field.Type().Elem())
+		pointer.Elem().Set(value)
+		field.Set(pointer)
+	case value.Kind() == reflect.Ptr && value.Type().Elem().AssignableTo(field.Type()):
+		if value.IsNil() {
+			return nil
+		}
+
+		field.Set(value.Elem())
+	default:
+		return fmt.Errorf("decoder returned %s, can not assign it to %s", value.Type(), field.Type())
+	}
+
+	return nil
+}
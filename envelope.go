@@ -0,0 +1,110 @@
+package untold
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// envelopeMagic starts header line of secret file in envelope format.
+	envelopeMagic = "untold-secret"
+	// EnvelopeVersion is the newest version of envelope format. Since version 2 encrypted payload
+	// binds secret value to its name and environment.
+	EnvelopeVersion = 2
+	// unboundEnvelopeVersion is the newest version of envelope format, which payload is the bare value.
+	unboundEnvelopeVersion = 1
+	// AlgorithmSealedBox is anonymous NaCl box: X25519 key exchange, XSalsa20 encryption and Poly1305 authentication.
+	AlgorithmSealedBox = "x25519-xsalsa20-poly1305"
+)
+
+// ErrUnsupportedEnvelope is returned for envelope of version or algorithm unknown to this version of the library.
+var ErrUnsupportedEnvelope = errors.New("unsupported envelope")
+
+// Envelope is the content of secret file. It starts with header line holding format version and algorithm,
+// followed by ciphertext for each recipient on a separate line. Files written before envelope format was
+// introduced have no header and are parsed as envelope of version 0.
+type Envelope struct {
+	Version     int
+	Algorithm   string
+	Ciphertexts []Ciphertext
+}
+
+// Bound reports whether payload of the envelope binds secret value to its name and environment.
+func (e Envelope) Bound() bool {
+	return e.Version > unboundEnvelopeVersion
+}
+
+// Ciphertext is secret value encrypted to a single recipient, identified by ID of its key.
+// Legacy ciphertexts have no key ID.
+type Ciphertext struct {
+	KeyID string
+	Data  []byte
+}
+
+// ParseEnvelope parses content of secret file.
+func ParseEnvelope(content []byte) (Envelope, error) {
+	lines := bytes.Split(bytes.TrimSpace(content), []byte("\n"))
+
+	envelope := Envelope{Algorithm: AlgorithmSealedBox}
+	if header := string(bytes.TrimSpace(lines[0])); strings.HasPrefix(header, envelopeMagic+" ") {
+		fields := strings.Fields(header)
+		if len(fields) != 3 || !strings.HasPrefix(fields[1], "v") {
+			return envelope, fmt.Errorf("malformed envelope header %q", header)
+		}
+
+		version, err := strconv.Atoi(strings.TrimPrefix(fields[1], "v"))
+		if err != nil {
+			return envelope, fmt.Errorf("malformed envelope version %q", fields[1])
+		}
+
+		envelope.Version, envelope.Algorithm, lines = version, fields[2], lines[1:]
+	}
+
+	if envelope.Version > EnvelopeVersion {
+		return envelope, fmt.Errorf("%w version %d", ErrUnsupportedEnvelope, envelope.Version)
+	}
+
+	if envelope.Algorithm != AlgorithmSealedBox {
+		return envelope, fmt.Errorf("%w algorithm %q", ErrUnsupportedEnvelope, envelope.Algorithm)
+	}
+
+	for i := range lines {
+		var ciphertext Ciphertext
+		line := bytes.TrimSpace(lines[i])
+		if j := bytes.IndexByte(line, ' '); j >= 0 {
+			ciphertext.KeyID, line = string(line[:j]), bytes.TrimSpace(line[j+1:])
+		}
+
+		data, err := Base64Decode(line)
+		if err != nil {
+			return envelope, fmt.Errorf("base64 decode: %w", err)
+		}
+
+		ciphertext.Data = data
+		envelope.Ciphertexts = append(envelope.Ciphertexts, ciphertext)
+	}
+
+	return envelope, nil
+}
+
+// Bytes formats envelope as content of secret file.
+func (e Envelope) Bytes() []byte {
+	var buffer bytes.Buffer
+	if e.Version > 0 {
+		fmt.Fprintf(&buffer, "%s v%d %s\n", envelopeMagic, e.Version, e.Algorithm)
+	}
+
+	for i := range e.Ciphertexts {
+		if e.Ciphertexts[i].KeyID != "" {
+			buffer.WriteString(e.Ciphertexts[i].KeyID + " ")
+		}
+
+		buffer.Write(Base64Encode(e.Ciphertexts[i].Data))
+		buffer.WriteString("\n")
+	}
+
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n"))
+}
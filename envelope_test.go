@@ -0,0 +1,46 @@
+package untold
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseEnvelope(t *testing.T) {
+	legacy, err := testFS.ReadFile("test/test/098f6bcd4621d373cade4e832627b4f6")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envelope, err := ParseEnvelope(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if envelope.Version != 0 || envelope.Algorithm != AlgorithmSealedBox || len(envelope.Ciphertexts) != 1 || envelope.Ciphertexts[0].KeyID != "" {
+		t.Errorf("unexpected legacy envelope %+v", envelope)
+	}
+
+	if string(envelope.Bytes()) != string(legacy) {
+		t.Errorf("expected legacy envelope to format as %q, got %q", legacy, envelope.Bytes())
+	}
+
+	current := Envelope{Version: EnvelopeVersion, Algorithm: AlgorithmSealedBox, Ciphertexts: []Ciphertext{{KeyID: "0a0b0c0d", Data: []byte("data")}}}
+
+	parsed, err := ParseEnvelope(current.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Version != EnvelopeVersion || parsed.Ciphertexts[0].KeyID != "0a0b0c0d" || string(parsed.Ciphertexts[0].Data) != "data" {
+		t.Errorf("unexpected envelope %+v", parsed)
+	}
+
+	for _, content := range []string{
+		"untold-secret v99 x25519-xsalsa20-poly1305\n0a0b0c0d ZGF0YQ",
+		"untold-secret v1 unknown-algorithm\n0a0b0c0d ZGF0YQ",
+	} {
+		if _, err := ParseEnvelope([]byte(content)); !errors.Is(err, ErrUnsupportedEnvelope) {
+			t.Errorf("expected ErrUnsupportedEnvelope, got %v", err)
+		}
+	}
+}
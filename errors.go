@@ -0,0 +1,89 @@
+package untold
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when secret does not exist in the environment.
+	ErrNotFound = errors.New("not found")
+	// ErrDecrypt is returned when secret exists, but can not be decrypted with provided keys.
+	ErrDecrypt = errors.New("can not decrypt")
+	// ErrClosed is returned when closed Vault is used.
+	ErrClosed = errors.New("vault is closed")
+	// ErrEmbeddedPrivateKey is returned when private key is embedded, but embedded keys are not allowed.
+	ErrEmbeddedPrivateKey = errors.New("embedded private key is not allowed")
+)
+
+// FieldError describes why Load could not fill a single field.
+type FieldError struct {
+	// Field is a path of the field within loaded struct, e.g. "Database.Password".
+	Field string
+	// Secret is a name of the secret the field is filled from.
+	Secret string
+	// Environment is a name of the environment the secret was found or failed to open in.
+	// Missing secret lists every searched environment, comma separated, e.g. "staging, shared".
+	Environment string
+	// Err is the cause.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	if e.Environment == "" {
+		return fmt.Sprintf("field %q, secret %q: %v", e.Field, e.Secret, e.Err)
+	}
+
+	return fmt.Sprintf("field %q, secret %q, environment %q: %v", e.Field, e.Secret, e.Environment, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// LoadError joins errors of all fields Load could not fill.
+// errors.Is and errors.As match against causes of every field error.
+type LoadError struct {
+	Errors []*FieldError
+}
+
+func (e *LoadError) Error() string {
+	if len(e.Errors) == 1 {
+		return "load secrets: " + e.Errors[0].Error()
+	}
+
+	messages := make([]string, len(e.Errors))
+	for i := range e.Errors {
+		messages[i] = "\t" + e.Errors[i].Error()
+	}
+
+	return fmt.Sprintf("load secrets: %d errors:\n%s", len(e.Errors), strings.Join(messages, "\n"))
+}
+
+func (e *LoadError) Is(target error) bool {
+	for i := range e.Errors {
+		if errors.Is(e.Errors[i], target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *LoadError) As(target interface{}) bool {
+	for i := range e.Errors {
+		if errors.As(e.Errors[i], target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *LoadError) Unwrap() []error {
+	result := make([]error, len(e.Errors))
+	for i := range e.Errors {
+		result[i] = e.Errors[i]
+	}
+
+	return result
+}
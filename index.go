@@ -0,0 +1,65 @@
+package untold
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// IndexFile is the name of file in environment directory holding encrypted names of its secrets.
+// Index is a list of entries separated by empty lines, each entry is an envelope holding one or more names,
+// so names can be appended to the index without decrypting it. Like NameKeyFile, the name must not start
+// with dot or underscore to be embedded.
+const IndexFile = "index"
+
+// indexEntrySeparator separates entries of the index.
+var indexEntrySeparator = []byte("\n\n")
+
+// SealIndexEntry encrypts secret names of the environment to every recipient. Result is an entry of IndexFile.
+func SealIndexEntry(names []string, environment string, recipients [][32]byte) ([]byte, error) {
+	return SealSecret([]byte(strings.Join(names, "\n")), Binding{Name: IndexFile, Environment: environment}, recipients)
+}
+
+// AppendIndexEntry appends entry to the content of IndexFile.
+func AppendIndexEntry(content, entry []byte) []byte {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return entry
+	}
+
+	return append(append(content, indexEntrySeparator...), entry...)
+}
+
+// OpenIndex decrypts every entry of IndexFile of the environment and returns sorted secret names.
+func OpenIndex(content []byte, environment string, privateKeys [][32]byte) ([]string, error) {
+	var names []string
+	for i, entry := range bytes.Split(bytes.TrimSpace(content), indexEntrySeparator) {
+		if len(bytes.TrimSpace(entry)) == 0 {
+			continue
+		}
+
+		value, err := OpenSecret(entry, Binding{Name: IndexFile, Environment: environment}, privateKeys)
+		if err != nil {
+			return nil, fmt.Errorf("index entry %d: %w", i+1, err)
+		}
+
+		names = append(names, strings.Split(string(value), "\n")...)
+	}
+
+	return SortNames(names), nil
+}
+
+// SortNames sorts names and removes duplicates and empty names.
+func SortNames(names []string) []string {
+	sort.Strings(names)
+
+	result := names[:0]
+	for i := range names {
+		if names[i] != "" && (len(result) == 0 || result[len(result)-1] != names[i]) {
+			result = append(result, names[i])
+		}
+	}
+
+	return result
+}
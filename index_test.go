@@ -0,0 +1,52 @@
+package untold
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNames(t *testing.T) {
+	publicKey := testPublicKey(t)
+
+	var index []byte
+	for _, names := range [][]string{{"test", "db_password"}, {"api_key"}, {"test"}} {
+		entry, err := SealIndexEntry(names, "test", [][32]byte{publicKey})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		index = AppendIndexEntry(index, entry)
+	}
+
+	files := testFiles(t, "test/test.private")
+	files["test/test/"+IndexFile] = &fstest.MapFile{Data: index}
+	files["test/shared.private"] = files["test/test.private"]
+
+	names, err := NewVault(files, Environment("test"), Fallback("shared"), PathPrefix("test"), AllowEmbeddedPrivateKey()).Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"api_key", "db_password", "test"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %q, got %q", expected, names)
+	}
+
+	// index copied to other environment does not open
+	files["test/shared/"+IndexFile] = &fstest.MapFile{Data: index}
+
+	if _, err := NewVault(files, Environment("test"), Fallback("shared"), PathPrefix("test"), AllowEmbeddedPrivateKey()).Names(); err == nil {
+		t.Error("expected error for index of other environment")
+	}
+}
+
+func TestNamesFromEmbedFS(t *testing.T) {
+	names, err := NewVault(testFS, Environment("keyed"), PathPrefix("test"), AllowEmbeddedPrivateKey()).Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"test"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %q, got %q", expected, names)
+	}
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/damejeras/untold"
+	"io/fs"
+)
+
+// ReadIndex reads names of secrets listed in encrypted index of the environment.
+func ReadIndex(storage Storage, privateKeys [][32]byte) ([]string, error) {
+	content, err := storage.ReadFile(untold.IndexFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read index of %q environment: %w", storage.Environment(), err)
+	}
+
+	names, err := untold.OpenIndex(content, storage.Environment(), privateKeys)
+	if err != nil {
+		return nil, fmt.Errorf("open index of %q environment: %w", storage.Environment(), err)
+	}
+
+	return names, nil
+}
+
+// AppendIndex adds secret name to encrypted index of the environment. Index is not decrypted,
+// so only public keys of the environment are needed.
+func AppendIndex(storage Storage, name string, recipients [][32]byte) error {
+	entry, err := untold.SealIndexEntry([]string{name}, storage.Environment(), recipients)
+	if err != nil {
+		return fmt.Errorf("encrypt index entry: %w", err)
+	}
+
+	content, err := storage.ReadFile(untold.IndexFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("read index of %q environment: %w", storage.Environment(), err)
+	}
+
+	if err := storage.WriteFile(untold.IndexFile, untold.AppendIndexEntry(content, entry)); err != nil {
+		return fmt.Errorf("write index of %q environment: %w", storage.Environment(), err)
+	}
+
+	return nil
+}
+
+// WriteIndex replaces encrypted index of the environment with a single entry listing given names.
+func WriteIndex(storage Storage, names []string, recipients [][32]byte) error {
+	entry, err := untold.SealIndexEntry(untold.SortNames(names), storage.Environment(), recipients)
+	if err != nil {
+		return fmt.Errorf("encrypt index: %w", err)
+	}
+
+	if err := storage.WriteFile(untold.IndexFile, entry); err != nil {
+		return fmt.Errorf("write index of %q environment: %w", storage.Environment(), err)
+	}
+
+	return nil
+}
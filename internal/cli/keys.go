@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/damejeras/untold"
+	"io/fs"
+	"os"
+)
+
+// LoadKeys loads keys of the environment from current directory. Private key is decoded from
+// base64EncodedPrivateKey if it is not empty, otherwise it is read from {environment}.private file.
+// Public key is derived from private key and checked against {environment}.public file, if it exists.
+// Private key of any environment's recipient is accepted as well.
+func LoadKeys(environment string, base64EncodedPrivateKey []byte) (publicKey, privateKey [32]byte, err error) {
+	if len(base64EncodedPrivateKey) == 0 {
+		base64EncodedPrivateKey, err = os.ReadFile(environment + ".private")
+		if errors.Is(err, fs.ErrNotExist) {
+			return publicKey, privateKey, fmt.Errorf("private key for %q environment not found", environment)
+		}
+
+		if err != nil {
+			return publicKey, privateKey, fmt.Errorf("read private key for %q environment: %w", environment, err)
+		}
+	}
+
+	privateKey, err = untold.DecodePrivateKey(base64EncodedPrivateKey, Passphrase(environment))
+	if err != nil {
+		return publicKey, privateKey, fmt.Errorf("decode private key for %q environment: %w", environment, err)
+	}
+
+	publicKey = untold.DerivePublicKey(privateKey)
+
+	storedPublicKey, err := readPublicKey(environment)
+	if errors.Is(err, fs.ErrNotExist) {
+		return publicKey, privateKey, nil
+	}
+
+	if err != nil {
+		return publicKey, privateKey, err
+	}
+
+	if storedPublicKey == publicKey {
+		return publicKey, privateKey, nil
+	}
+
+	recipients, err := LoadRecipients(environment)
+	if err != nil {
+		return publicKey, privateKey, err
+	}
+
+	for i := range recipients {
+		if recipients[i].PublicKey == publicKey {
+			return publicKey, privateKey, nil
+		}
+	}
+
+	return publicKey, privateKey, fmt.Errorf("%w for environment %q", untold.ErrKeyMismatch, environment)
+}
+
+// LoadRecipients loads recipients of the environment from {environment}.recipients file, if it exists.
+func LoadRecipients(environment string) ([]untold.Recipient, error) {
+	content, err := os.ReadFile(environment + ".recipients")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("read recipients for %q environment: %w", environment, err)
+	}
+
+	recipients, err := untold.ParseRecipients(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse recipients for %q environment: %w", environment, err)
+	}
+
+	return recipients, nil
+}
+
+// RecipientKeys returns public keys secrets of the environment are encrypted to:
+// environment's public key followed by public keys of its recipients.
+func RecipientKeys(environment string) ([][32]byte, error) {
+	publicKey, err := LoadPublicKey(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients, err := LoadRecipients(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := [][32]byte{publicKey}
+	for i := range recipients {
+		keys = append(keys, recipients[i].PublicKey)
+	}
+
+	return keys, nil
+}
+
+// LoadPublicKey loads public key of the environment from {environment}.public file. If the file
+// does not exist, public key is derived from private key stored in {environment}.private file.
+func LoadPublicKey(environment string) ([32]byte, error) {
+	publicKey, err := readPublicKey(environment)
+	if errors.Is(err, fs.ErrNotExist) {
+		publicKey, _, err = LoadKeys(environment, nil)
+		if err != nil {
+			return publicKey, fmt.Errorf("public key for %q environment not found: %w", environment, err)
+		}
+	}
+
+	return publicKey, err
+}
+
+func readPublicKey(environment string) (publicKey [32]byte, err error) {
+	base64EncodedPublicKey, err := os.ReadFile(environment + ".public")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return publicKey, err
+		}
+
+		return publicKey, fmt.Errorf("read public key for %q environment: %w", environment, err)
+	}
+
+	publicKey, err = untold.DecodeBase64Key(base64EncodedPublicKey)
+	if err != nil {
+		return publicKey, fmt.Errorf("decode base64 encoded public key for %q environment: %w", environment, err)
+	}
+
+	return publicKey, nil
+}
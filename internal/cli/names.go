@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/damejeras/untold"
+	"io/fs"
+)
+
+// KeyedNames reports whether secret files of the environment are named by keyed hash of secret's name.
+func KeyedNames(storage Storage) bool {
+	_, err := storage.ReadFile(untold.NameKeyFile)
+
+	return err == nil
+}
+
+// NameKey loads key of keyed secret file names of the environment, nil if secret files are named by MD5 hash.
+func NameKey(storage Storage, privateKeys [][32]byte) ([]byte, error) {
+	content, err := storage.ReadFile(untold.NameKeyFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read name key for %q environment: %w", storage.Environment(), err)
+	}
+
+	nameKey, err := untold.OpenNameKey(content, storage.Environment(), privateKeys)
+	if err != nil {
+		return nil, fmt.Errorf("open name key for %q environment: %w", storage.Environment(), err)
+	}
+
+	return nameKey, nil
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/damejeras/untold"
+	"golang.org/x/term"
+	"os"
+)
+
+// ReadPassphrase prints prompt and reads passphrase. Input is not echoed if stdin is a terminal.
+func ReadPassphrase(template string, args ...interface{}) ([]byte, error) {
+	fmt.Printf(template+"\n", args...)
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return term.ReadPassword(int(os.Stdin.Fd()))
+	}
+
+	var value string
+	if _, err := fmt.Scanln(&value); err != nil {
+		return nil, err
+	}
+
+	return []byte(value), nil
+}
+
+// NewPassphrase asks for new passphrase of environment's private key twice and checks both entries match.
+func NewPassphrase(environment string) ([]byte, error) {
+	passphrase, err := ReadPassphrase("Enter new passphrase for %q environment private key:", environment)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase is empty")
+	}
+
+	confirmation, err := ReadPassphrase("Repeat passphrase:")
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(passphrase, confirmation) {
+		return nil, errors.New("passphrases do not match")
+	}
+
+	return passphrase, nil
+}
+
+// Passphrase returns function prompting for passphrase of environment's encrypted private key.
+func Passphrase(environment string) untold.PassphraseFunc {
+	return func() ([]byte, error) {
+		return ReadPassphrase("Enter passphrase for %q environment private key:", environment)
+	}
+}
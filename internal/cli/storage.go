@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/damejeras/untold"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// gitkeepFile keeps empty environment directory in git.
+const gitkeepFile = ".gitkeep"
+
+// Storage holds secret files of the environment, either in environment directory or in bundle file.
+type Storage interface {
+	// Environment returns name of the environment.
+	Environment() string
+	// ReadFile reads file of the environment. Error wraps fs.ErrNotExist if file does not exist.
+	ReadFile(name string) ([]byte, error)
+	// WriteFile creates or replaces file of the environment.
+	WriteFile(name string, content []byte) error
+	// Remove removes file of the environment.
+	Remove(name string) error
+	// Files returns sorted names of files of the environment.
+	Files() ([]string, error)
+}
+
+// BundlePath returns path of bundle file of the environment.
+func BundlePath(environment string) string {
+	return environment + untold.BundleExtension
+}
+
+// OpenStorage opens storage of the environment in current directory. Bundle file takes precedence over directory.
+func OpenStorage(environment string) (Storage, error) {
+	content, err := os.ReadFile(BundlePath(environment))
+	if err == nil {
+		files, err := untold.ParseBundle(content)
+		if err != nil {
+			return nil, fmt.Errorf("parse bundle of %q environment: %w", environment, err)
+		}
+
+		return &bundleStorage{environment: environment, files: files}, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read bundle of %q environment: %w", environment, err)
+	}
+
+	if _, err := os.Stat(environment); err != nil {
+		return nil, fmt.Errorf("directory for %q environment not found: %w", environment, err)
+	}
+
+	return directoryStorage(environment), nil
+}
+
+// CreateBundle creates empty bundle file of the environment.
+func CreateBundle(environment string) (Storage, error) {
+	storage := &bundleStorage{environment: environment, files: make(map[string][]byte)}
+
+	return storage, storage.save()
+}
+
+// directoryStorage stores each file of the environment in environment directory.
+type directoryStorage string
+
+func (d directoryStorage) Environment() string { return string(d) }
+
+func (d directoryStorage) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(string(d), name))
+}
+
+func (d directoryStorage) WriteFile(name string, content []byte) error {
+	return os.WriteFile(filepath.Join(string(d), name), content, 0644)
+}
+
+func (d directoryStorage) Remove(name string) error {
+	return os.Remove(filepath.Join(string(d), name))
+}
+
+func (d directoryStorage) Files() ([]string, error) {
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, fmt.Errorf("read environment %q directory: %w", string(d), err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && entry.Name() != gitkeepFile {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// bundleStorage stores all files of the environment in bundle file. Bundle file is rewritten on every change.
+type bundleStorage struct {
+	environment string
+	files       map[string][]byte
+}
+
+func (b *bundleStorage) Environment() string { return b.environment }
+
+func (b *bundleStorage) ReadFile(name string) ([]byte, error) {
+	content, ok := b.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s in %s: %w", name, BundlePath(b.environment), fs.ErrNotExist)
+	}
+
+	return content, nil
+}
+
+func (b *bundleStorage) WriteFile(name string, content []byte) error {
+	b.files[name] = content
+
+	return b.save()
+}
+
+func (b *bundleStorage) Remove(name string) error {
+	if _, ok := b.files[name]; !ok {
+		return fmt.Errorf("%s in %s: %w", name, BundlePath(b.environment), fs.ErrNotExist)
+	}
+
+	delete(b.files, name)
+
+	return b.save()
+}
+
+func (b *bundleStorage) Files() ([]string, error) {
+	names := make([]string, 0, len(b.files))
+	for name := range b.files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (b *bundleStorage) save() error {
+	content, err := untold.FormatBundle(b.files)
+	if err != nil {
+		return fmt.Errorf("format bundle of %q environment: %w", b.environment, err)
+	}
+
+	return os.WriteFile(BundlePath(b.environment), content, 0644)
+}
@@ -2,21 +2,17 @@ package secret
 
 import (
 	"context"
-	"crypto/md5"
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/damejeras/untold"
 	"github.com/damejeras/untold/internal/cli"
 	"github.com/google/subcommands"
-	"golang.org/x/crypto/nacl/box"
-	"os"
-	"path/filepath"
+	"io/fs"
 )
 
 type addCmd struct {
-	environment string
+	environment, privateKey string
 }
 
 func NewAddCommand() subcommands.Command { return &addCmd{environment: untold.DefaultEnvironment} }
@@ -26,13 +22,14 @@ func (a *addCmd) Name() string { return "add-secret" }
 func (a *addCmd) Synopsis() string { return "add new secret" }
 
 func (a *addCmd) Usage() string {
-	return `untold add-secret [-env={environment}] <secret_name>:
-  Add new secret.
+	return `untold add-secret [-env={environment}] [-key={decryption_key}] <secret_name>:
+  Add new secret. Decryption key is needed only if environment names secret files by keyed hash.
 `
 }
 
 func (a *addCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&a.environment, "env", a.environment, "set environment")
+	f.StringVar(&a.privateKey, "key", a.privateKey, "provide decryption key")
 }
 
 func (a *addCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
@@ -44,43 +41,53 @@ func (a *addCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface
 		return subcommands.ExitUsageError
 	}
 
+	if name == untold.NameKeyFile || name == untold.IndexFile {
+		cli.Errorf("secret name %q is reserved", name)
+
+		return subcommands.ExitUsageError
+	}
+
 	environment := a.environment
 	if environment == "" || environment == untold.DefaultEnvironment {
 		environment = untold.DefaultEnvironment
 		cli.Warnf("No environment provided, using default - %q", environment)
 	}
 
-	md5Hash := md5.Sum([]byte(name))
-
-	if _, err := os.Stat(filepath.Join(environment, hex.EncodeToString(md5Hash[:]))); !os.IsNotExist(err) {
-		cli.Errorf("secret %q for %q environment already exists", name, environment)
+	storage, err := cli.OpenStorage(environment)
+	if err != nil {
+		cli.Wrapf(err, "open %q environment", environment)
 
-		return subcommands.ExitUsageError
+		return subcommands.ExitFailure
 	}
 
-	if _, err := os.Stat(environment); os.IsNotExist(err) {
-		cli.Errorf("directory for %q environment not found", environment)
+	var nameKey []byte
+	if cli.KeyedNames(storage) {
+		_, privateKey, err := cli.LoadKeys(environment, []byte(a.privateKey))
+		if err != nil {
+			cli.Wrapf(err, "load keys for %q environment", environment)
 
-		return subcommands.ExitFailure
-	}
+			return subcommands.ExitFailure
+		}
 
-	if _, err := os.Stat(environment + ".public"); os.IsNotExist(err) {
-		cli.Errorf("public key for %q environment not found", environment)
+		nameKey, err = cli.NameKey(storage, [][32]byte{privateKey})
+		if err != nil {
+			cli.Wrapf(err, "load name key for %q environment", environment)
 
-		return subcommands.ExitFailure
+			return subcommands.ExitFailure
+		}
 	}
 
-	base64EncodedPublicKey, err := os.ReadFile(environment + ".public")
-	if err != nil {
-		cli.Wrapf(err, "read public key for %q environment", environment)
+	filename := untold.SecretFileName(name, nameKey)
 
-		return subcommands.ExitFailure
+	if _, err := storage.ReadFile(filename); !errors.Is(err, fs.ErrNotExist) {
+		cli.Errorf("secret %q for %q environment already exists", name, environment)
+
+		return subcommands.ExitUsageError
 	}
 
-	var publicKey [32]byte
-	publicKey, err = untold.DecodeBase64Key(base64EncodedPublicKey)
+	recipients, err := cli.RecipientKeys(environment)
 	if err != nil {
-		cli.Wrapf(err, "decode base64 encoded public key for %q environment", environment)
+		cli.Wrapf(err, "load public keys for %q environment", environment)
 
 		return subcommands.ExitFailure
 	}
@@ -95,20 +102,26 @@ func (a *addCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface
 		return subcommands.ExitFailure
 	}
 
-	encryptedValue, err := box.SealAnonymous(nil, []byte(value), &publicKey, rand.Reader)
+	encryptedValue, err := untold.SealSecret([]byte(value), untold.Binding{Name: name, Environment: environment}, recipients)
 	if err != nil {
 		cli.Wrapf(err, "encrypt user input")
 
 		return subcommands.ExitFailure
 	}
 
-	err = os.WriteFile(filepath.Join(environment, hex.EncodeToString(md5Hash[:])), untold.Base64Encode(encryptedValue), 0644)
+	err = storage.WriteFile(filename, encryptedValue)
 	if err != nil {
 		cli.Wrapf(err, "write secret %q for %q environment to file", name, environment)
 
 		return subcommands.ExitFailure
 	}
 
+	if err := cli.AppendIndex(storage, name, recipients); err != nil {
+		cli.Wrapf(err, "add secret %q to index of %q environment", name, environment)
+
+		return subcommands.ExitFailure
+	}
+
 	cli.Successf("Secret %q for %q environment stored.", name, environment)
 
 	return subcommands.ExitSuccess
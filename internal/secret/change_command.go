@@ -2,17 +2,14 @@ package secret
 
 import (
 	"context"
-	"crypto/md5"
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/damejeras/untold"
 	"github.com/damejeras/untold/internal/cli"
 	"github.com/google/subcommands"
-	"golang.org/x/crypto/nacl/box"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"sort"
 )
 
 type changeCmd struct {
@@ -51,77 +48,51 @@ func (c *changeCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interf
 		cli.Warnf("No environment provided, using default - %q", environment)
 	}
 
-	md5Hash := md5.Sum([]byte(name))
-	base64EncodedPrivateKey := []byte(c.privateKey)
-
-	if _, err := os.Stat(filepath.Join(environment, hex.EncodeToString(md5Hash[:]))); os.IsNotExist(err) {
-		cli.Errorf("secret %q for %q environment not found", name, environment)
-
-		return subcommands.ExitUsageError
-	}
-
-	if _, err := os.Stat(environment + ".public"); os.IsNotExist(err) {
-		cli.Errorf("public key for %q environment not found", environment)
+	storage, err := cli.OpenStorage(environment)
+	if err != nil {
+		cli.Wrapf(err, "open %q environment", environment)
 
 		return subcommands.ExitFailure
 	}
 
-	if len(base64EncodedPrivateKey) == 0 {
-		if _, err := os.Stat(environment + ".private"); os.IsNotExist(err) {
-			cli.Errorf("private key for %q environment not found", environment)
-
-			return subcommands.ExitFailure
-		}
-	}
-
-	base64EncodedPublicKey, err := os.ReadFile(environment + ".public")
+	_, privateKey, err := cli.LoadKeys(environment, []byte(c.privateKey))
 	if err != nil {
-		cli.Wrapf(err, "read public key for %q environment", environment)
+		cli.Wrapf(err, "load keys for %q environment", environment)
 
 		return subcommands.ExitFailure
 	}
 
-	if len(base64EncodedPrivateKey) == 0 {
-		base64EncodedPrivateKey, err = os.ReadFile(environment + ".private")
-		if err != nil {
-			cli.Wrapf(err, "read private key for %q environment", environment)
-
-			return subcommands.ExitFailure
-		}
-	}
-
-	base64EncodedContent, err := os.ReadFile(filepath.Join(environment, hex.EncodeToString(md5Hash[:])))
+	nameKey, err := cli.NameKey(storage, [][32]byte{privateKey})
 	if err != nil {
-		cli.Wrapf(err, "read secret %q for %q environment", name, environment)
+		cli.Wrapf(err, "load name key for %q environment", environment)
 
 		return subcommands.ExitFailure
 	}
 
-	var publicKey, privateKey [32]byte
-	publicKey, err = untold.DecodeBase64Key(base64EncodedPublicKey)
-	if err != nil {
-		cli.Wrapf(err, "decode base64 encoded public key for %q environment", environment)
+	filename := untold.SecretFileName(name, nameKey)
 
-		return subcommands.ExitFailure
+	base64EncodedContent, err := storage.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		cli.Errorf("secret %q for %q environment not found", name, environment)
+
+		return subcommands.ExitUsageError
 	}
 
-	privateKey, err = untold.DecodeBase64Key(base64EncodedPrivateKey)
 	if err != nil {
-		cli.Wrapf(err, "decode base64 encoded private key for %q environment", environment)
+		cli.Wrapf(err, "read secret %q for %q environment", name, environment)
 
 		return subcommands.ExitFailure
 	}
 
-	encryptedSecret, err := untold.Base64Decode(base64EncodedContent)
-	if err != nil {
-		cli.Wrapf(err, "decode base64 secret %q for %q environment", name, environment)
+	if _, err := untold.OpenSecret(base64EncodedContent, untold.Binding{Name: name, Environment: environment}, [][32]byte{privateKey}); err != nil {
+		cli.Wrapf(err, "decrypt %q secret for %q environment", name, environment)
 
 		return subcommands.ExitFailure
 	}
 
-	_, ok := box.OpenAnonymous(nil, encryptedSecret, &publicKey, &privateKey)
-	if !ok {
-		cli.Errorf("can not decrypt %q secret for %q environment", name, environment)
+	recipients, err := cli.RecipientKeys(environment)
+	if err != nil {
+		cli.Wrapf(err, "load public keys for %q environment", environment)
 
 		return subcommands.ExitFailure
 	}
@@ -137,20 +108,35 @@ func (c *changeCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interf
 	}
 
 
-	encryptedValue, err := box.SealAnonymous(nil, []byte(value), &publicKey, rand.Reader)
+	encryptedValue, err := untold.SealSecret([]byte(value), untold.Binding{Name: name, Environment: environment}, recipients)
 	if err != nil {
 		cli.Wrapf(err, "encrypt user input")
 
 		return subcommands.ExitFailure
 	}
 
-	err = os.WriteFile(filepath.Join(environment, hex.EncodeToString(md5Hash[:])), untold.Base64Encode(encryptedValue), 0644)
+	err = storage.WriteFile(filename, encryptedValue)
 	if err != nil {
 		cli.Wrapf(err, "write secret %q for %q environment to file", name, environment)
 
 		return subcommands.ExitFailure
 	}
 
+	names, err := cli.ReadIndex(storage, [][32]byte{privateKey})
+	if err != nil {
+		cli.Wrapf(err, "read index of %q environment", environment)
+
+		return subcommands.ExitFailure
+	}
+
+	if i := sort.SearchStrings(names, name); i == len(names) || names[i] != name {
+		if err := cli.AppendIndex(storage, name, recipients); err != nil {
+			cli.Wrapf(err, "add secret %q to index of %q environment", name, environment)
+
+			return subcommands.ExitFailure
+		}
+	}
+
 	cli.Successf("Secret's %q value changed", name)
 
 	return subcommands.ExitSuccess
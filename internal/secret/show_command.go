@@ -2,19 +2,17 @@ package secret
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/hex"
+	"errors"
 	"flag"
 	"github.com/damejeras/untold"
 	"github.com/damejeras/untold/internal/cli"
 	"github.com/google/subcommands"
-	"golang.org/x/crypto/nacl/box"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"strings"
 )
 
 type showCmd struct {
-	environment, privateKey string
+	environment, privateKey, fallback string
 }
 
 func NewShowCommand() subcommands.Command { return &showCmd{environment: untold.DefaultEnvironment} }
@@ -24,7 +22,7 @@ func (s *showCmd) Name() string { return "show-secret" }
 func (s *showCmd) Synopsis() string { return "show secret's value" }
 
 func (s *showCmd) Usage() string {
-	return `untold show-secret [-env={environment}] [-key={decryption_key}] <secret_name>:
+	return `untold show-secret [-env={environment}] [-key={decryption_key}] [-fallback={environment,...}] <secret_name>:
   Show decrypted secret value.
 `
 }
@@ -32,6 +30,7 @@ func (s *showCmd) Usage() string {
 func (s *showCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&s.environment, "env", s.environment, "set environment")
 	f.StringVar(&s.privateKey, "key", s.privateKey, "provide decryption key")
+	f.StringVar(&s.fallback, "fallback", s.fallback, "comma separated environments to search if secret is missing")
 }
 
 func (s *showCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
@@ -49,82 +48,93 @@ func (s *showCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interfac
 		cli.Warnf("No environment provided, using default - %q", environment)
 	}
 
-	md5Hash := md5.Sum([]byte(name))
-	base64EncodedPrivateKey := []byte(s.privateKey)
-
-	if _, err := os.Stat(filepath.Join(environment, hex.EncodeToString(md5Hash[:]))); os.IsNotExist(err) {
-		cli.Errorf("secret %q for %q environment not found", name, environment)
-
-		return subcommands.ExitUsageError
+	environments := []string{environment}
+	if s.fallback != "" {
+		environments = append(environments, strings.Split(s.fallback, ",")...)
 	}
 
-	if _, err := os.Stat(environment + ".public"); os.IsNotExist(err) {
-		cli.Errorf("public key for %q environment not found", environment)
+	var (
+		privateKey           [32]byte
+		base64EncodedContent []byte
+	)
 
-		return subcommands.ExitFailure
-	}
+	// decryption key provided by flag belongs to the main environment only
+	for i := range environments {
+		base64EncodedPrivateKey := []byte(s.privateKey)
+		if environments[i] != environment {
+			base64EncodedPrivateKey = nil
+		}
 
-	if len(base64EncodedPrivateKey) == 0 {
-		if _, err := os.Stat(environment + ".private"); os.IsNotExist(err) {
-			cli.Errorf("private key for %q environment not found", environment)
+		storage, err := cli.OpenStorage(environments[i])
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			cli.Wrapf(err, "open %q environment", environments[i])
 
 			return subcommands.ExitFailure
 		}
-	}
 
-	base64EncodedPublicKey, err := os.ReadFile(environment + ".public")
-	if err != nil {
-		cli.Wrapf(err, "read public key for %q environment", environment)
+		if storage != nil {
+			var nameKey []byte
+			keysLoaded := cli.KeyedNames(storage)
+			if keysLoaded {
+				_, privateKey, err = cli.LoadKeys(environments[i], base64EncodedPrivateKey)
+				if err != nil {
+					cli.Wrapf(err, "load keys for %q environment", environments[i])
 
-		return subcommands.ExitFailure
-	}
+					return subcommands.ExitFailure
+				}
 
-	if len(base64EncodedPrivateKey) == 0 {
-		base64EncodedPrivateKey, err = os.ReadFile(environment + ".private")
-		if err != nil {
-			cli.Wrapf(err, "read private key for %q environment", environment)
+				nameKey, err = cli.NameKey(storage, [][32]byte{privateKey})
+				if err != nil {
+					cli.Wrapf(err, "load name key for %q environment", environments[i])
 
-			return subcommands.ExitFailure
-		}
-	}
+					return subcommands.ExitFailure
+				}
+			}
 
-	base64EncodedContent, err := os.ReadFile(filepath.Join(environment, hex.EncodeToString(md5Hash[:])))
-	if err != nil {
-		cli.Wrapf(err, "read secret %q for %q environment", name, environment)
+			base64EncodedContent, err = storage.ReadFile(untold.SecretFileName(name, nameKey))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				cli.Wrapf(err, "read secret %q for %q environment", name, environments[i])
 
-		return subcommands.ExitFailure
-	}
+				return subcommands.ExitFailure
+			}
 
-	var publicKey, privateKey [32]byte
-	publicKey, err = untold.DecodeBase64Key(base64EncodedPublicKey)
-	if err != nil {
-		cli.Wrapf(err, "decode base64 encoded public key for %q environment", environment)
+			if err == nil {
+				if !keysLoaded {
+					_, privateKey, err = cli.LoadKeys(environments[i], base64EncodedPrivateKey)
+					if err != nil {
+						cli.Wrapf(err, "load keys for %q environment", environments[i])
 
-		return subcommands.ExitFailure
-	}
+						return subcommands.ExitFailure
+					}
+				}
 
-	privateKey, err = untold.DecodeBase64Key(base64EncodedPrivateKey)
-	if err != nil {
-		cli.Wrapf(err, "decode base64 encoded private key for %q environment", environment)
+				environment = environments[i]
 
-		return subcommands.ExitFailure
-	}
+				break
+			}
+		}
 
-	decodedContent, err := untold.Base64Decode(base64EncodedContent)
-	if err != nil {
-		cli.Wrapf(err, "decode secret %q key for %q environment", name, environment)
+		if i == len(environments)-1 && len(environments) == 1 {
+			cli.Errorf("secret %q for %q environment not found", name, environment)
 
-		return subcommands.ExitFailure
+			return subcommands.ExitUsageError
+		}
+
+		if i == len(environments)-1 {
+			cli.Errorf("secret %q not found in any of %q environments", name, environments)
+
+			return subcommands.ExitUsageError
+		}
 	}
 
-	decryptedValue, ok := box.OpenAnonymous(nil, decodedContent, &publicKey, &privateKey)
-	if !ok {
-		cli.Errorf("can not decrypt secret %q", name)
+	decryptedValue, err := untold.OpenSecret(base64EncodedContent, untold.Binding{Name: name, Environment: environment}, [][32]byte{privateKey})
+	if err != nil {
+		cli.Wrapf(err, "decrypt secret %q", name)
 
 		return subcommands.ExitFailure
 	}
 
-	cli.Successf("Secret's %q value is: %s", name, decryptedValue)
+	cli.Successf("Secret's %q value from %q environment is: %s", name, environment, decryptedValue)
 
 	return subcommands.ExitSuccess
 }
@@ -0,0 +1,170 @@
+package untold
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"github.com/damejeras/untold"
+	"github.com/damejeras/untold/internal/cli"
+	"github.com/google/subcommands"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	embedDirective = "//go:embed"
+	allPrefix      = "all:"
+)
+
+type checkEmbedCmd struct {
+	directory string
+}
+
+func NewCheckEmbedCommand() subcommands.Command { return &checkEmbedCmd{directory: "."} }
+
+func (c *checkEmbedCmd) Name() string { return "check-embed" }
+
+func (c *checkEmbedCmd) Synopsis() string { return "report embedded private keys" }
+
+func (c *checkEmbedCmd) Usage() string {
+	return `untold check-embed [-dir={package_directory}] [pattern ...]:
+  Report environments, which private keys would be embedded by //go:embed directive with given patterns.
+  Without patterns, directives are read from Go files of the package directory.
+`
+}
+
+func (c *checkEmbedCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.directory, "dir", c.directory, "set package directory")
+}
+
+func (c *checkEmbedCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	patterns := f.Args()
+	if len(patterns) == 0 {
+		var err error
+		patterns, err = readEmbedPatterns(c.directory)
+		if err != nil {
+			cli.Wrapf(err, "read //go:embed directives in %q", c.directory)
+
+			return subcommands.ExitFailure
+		}
+	}
+
+	if len(patterns) == 0 {
+		cli.Warnf("No //go:embed directives found in %q", c.directory)
+
+		return subcommands.ExitSuccess
+	}
+
+	privateKeys, err := embeddedPrivateKeys(c.directory, patterns)
+	if err != nil {
+		cli.Wrapf(err, "resolve embedded files")
+
+		return subcommands.ExitFailure
+	}
+
+	status := subcommands.ExitSuccess
+	for _, privateKey := range privateKeys {
+		environment := strings.TrimSuffix(filepath.Base(privateKey), ".private")
+		if environment == untold.DefaultEnvironment {
+			cli.Warnf("Private key of %q environment is embedded from %q", environment, privateKey)
+
+			continue
+		}
+
+		cli.Errorf("private key of %q environment is embedded from %q", environment, privateKey)
+		status = subcommands.ExitFailure
+	}
+
+	if len(privateKeys) == 0 {
+		cli.Successf("No private keys are embedded.")
+	}
+
+	return status
+}
+
+// readEmbedPatterns reads patterns of //go:embed directives in Go files of the directory.
+func readEmbedPatterns(directory string) ([]string, error) {
+	goFiles, err := filepath.Glob(filepath.Join(directory, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for i := range goFiles {
+		file, err := os.Open(goFiles[i])
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, embedDirective+" ") {
+				continue
+			}
+
+			for _, pattern := range strings.Fields(strings.TrimPrefix(line, embedDirective)) {
+				if unquoted, err := strconv.Unquote(pattern); err == nil {
+					pattern = unquoted
+				}
+
+				patterns = append(patterns, pattern)
+			}
+		}
+
+		file.Close()
+
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return patterns, nil
+}
+
+// embeddedPrivateKeys returns paths of private key files matched by embed patterns. Like go:embed,
+// files starting with '.' or '_' in matched directories are skipped, unless pattern has "all:" prefix.
+func embeddedPrivateKeys(directory string, patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+
+	var privateKeys []string
+	for _, pattern := range patterns {
+		all := strings.HasPrefix(pattern, allPrefix)
+
+		matches, err := filepath.Glob(filepath.Join(directory, filepath.FromSlash(strings.TrimPrefix(pattern, allPrefix))))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, match := range matches {
+			err := filepath.WalkDir(match, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if path != match && !all && (strings.HasPrefix(entry.Name(), ".") || strings.HasPrefix(entry.Name(), "_")) {
+					if entry.IsDir() {
+						return filepath.SkipDir
+					}
+
+					return nil
+				}
+
+				if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".private") && !seen[path] {
+					seen[path] = true
+					privateKeys = append(privateKeys, path)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return privateKeys, nil
+}
@@ -18,6 +18,7 @@ var templates embed.FS
 
 type initCmd struct {
 	environment string
+	passphrase  bool
 }
 
 func NewInitCommand() subcommands.Command { return &initCmd{ environment: untold.DefaultEnvironment } }
@@ -27,13 +28,14 @@ func (i *initCmd) Name() string { return "init" }
 func (i *initCmd) Synopsis() string { return "initialize secrets vault." }
 
 func (i *initCmd) Usage() string {
-	return `untold init [-env={environment}] [directory_name]:
+	return `untold init [-env={environment}] [-passphrase] [directory_name]:
   Initialize secrets vault.
 `
 }
 
 func (i *initCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&i.environment, "env", i.environment, "set environment")
+	f.BoolVar(&i.passphrase, "passphrase", i.passphrase, "encrypt private key with passphrase")
 }
 
 func (i *initCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
@@ -114,7 +116,24 @@ func (i *initCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interfac
 		return subcommands.ExitFailure
 	}
 
-	if err := os.WriteFile(filepath.Join(directory, environment+".private"), untold.Base64Encode(privateKey[:]), 0644); err != nil {
+	privateKeyContent := untold.Base64Encode(privateKey[:])
+	if i.passphrase {
+		passphrase, err := cli.NewPassphrase(environment)
+		if err != nil {
+			cli.Wrapf(err, "read passphrase")
+
+			return subcommands.ExitFailure
+		}
+
+		privateKeyContent, err = untold.EncryptPrivateKey(*privateKey, passphrase)
+		if err != nil {
+			cli.Wrapf(err, "encrypt private key for environment %q", environment)
+
+			return subcommands.ExitFailure
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(directory, environment+".private"), privateKeyContent, 0600); err != nil {
 		cli.Wrapf(err, "write private key for environment %q", environment)
 
 		return subcommands.ExitFailure
@@ -0,0 +1,139 @@
+package vault
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/damejeras/untold"
+	"github.com/damejeras/untold/internal/cli"
+	"github.com/google/subcommands"
+	"os"
+	"strings"
+)
+
+// commandSeparator separates share files from the command, which is run with rebuilt key.
+const commandSeparator = "--"
+
+type combineKeyCmd struct {
+	identities string
+}
+
+func NewCombineKeyCommand() subcommands.Command { return &combineKeyCmd{} }
+
+func (c *combineKeyCmd) Name() string { return "combine-key" }
+
+func (c *combineKeyCmd) Synopsis() string { return "rebuild private key from shares" }
+
+func (c *combineKeyCmd) Usage() string {
+	return `untold combine-key [-identity={private_key_file},...] <share_file>... [-- <command> [command_args]]:
+  Rebuild environment private key from shares written by split-key command and print it.
+  If command is given, e.g. "-- show-secret -env=production <secret_name>", it is run with the key
+  passed in its -key flag instead. Encrypted shares are decrypted with private keys of -identity files.
+`
+}
+
+func (c *combineKeyCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.identities, "identity", c.identities, "comma separated private key files of share holders")
+}
+
+func (c *combineKeyCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	shareFiles, command := f.Args(), []string(nil)
+	for i := range shareFiles {
+		if shareFiles[i] == commandSeparator {
+			shareFiles, command = shareFiles[:i], shareFiles[i+1:]
+
+			break
+		}
+	}
+
+	if len(shareFiles) == 0 {
+		cli.Errorf("argument \"share_file\" is required")
+		c.Usage()
+
+		return subcommands.ExitUsageError
+	}
+
+	var identities [][32]byte
+	if c.identities != "" {
+		for _, identity := range strings.Split(c.identities, ",") {
+			content, err := os.ReadFile(identity)
+			if err != nil {
+				cli.Wrapf(err, "read identity %q", identity)
+
+				return subcommands.ExitFailure
+			}
+
+			privateKeys, err := untold.DecodePrivateKeys(content, nil)
+			if err != nil {
+				cli.Wrapf(err, "decode identity %q", identity)
+
+				return subcommands.ExitFailure
+			}
+
+			identities = append(identities, privateKeys...)
+		}
+	}
+
+	shares := make([]untold.KeyShare, len(shareFiles))
+	for i := range shareFiles {
+		content, err := os.ReadFile(shareFiles[i])
+		if err != nil {
+			cli.Wrapf(err, "read share %q", shareFiles[i])
+
+			return subcommands.ExitFailure
+		}
+
+		shares[i], err = untold.ParseKeyShare(content, identities)
+		if err != nil {
+			cli.Wrapf(err, "parse share %q", shareFiles[i])
+
+			return subcommands.ExitFailure
+		}
+	}
+
+	privateKey, err := untold.CombineKey(shares)
+	if err != nil {
+		cli.Wrapf(err, "combine shares of %q environment", shares[0].Environment)
+
+		return subcommands.ExitFailure
+	}
+
+	if len(command) == 0 {
+		fmt.Printf("Private key of %q environment: %s\n", shares[0].Environment, privateKey)
+
+		return subcommands.ExitSuccess
+	}
+
+	return runWithKey(ctx, command, privateKey, args...)
+}
+
+// runWithKey runs registered command, which accepts decryption key in its -key flag, with given private key.
+func runWithKey(ctx context.Context, command []string, privateKey []byte, args ...interface{}) subcommands.ExitStatus {
+	var target subcommands.Command
+	subcommands.DefaultCommander.VisitCommands(func(_ *subcommands.CommandGroup, cmd subcommands.Command) {
+		if cmd.Name() == command[0] {
+			target = cmd
+		}
+	})
+
+	if target == nil {
+		cli.Errorf("command %q not found", command[0])
+
+		return subcommands.ExitUsageError
+	}
+
+	f := flag.NewFlagSet(target.Name(), flag.ContinueOnError)
+	target.SetFlags(f)
+
+	if f.Lookup("key") == nil {
+		cli.Errorf("command %q does not accept decryption key", target.Name())
+
+		return subcommands.ExitUsageError
+	}
+
+	if err := f.Parse(append([]string{"-key=" + string(privateKey)}, command[1:]...)); err != nil {
+		return subcommands.ExitUsageError
+	}
+
+	return target.Execute(ctx, f, args...)
+}
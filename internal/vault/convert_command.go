@@ -0,0 +1,157 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/damejeras/untold"
+	"github.com/damejeras/untold/internal/cli"
+	"github.com/google/subcommands"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	bundleLayout    = "bundle"
+	directoryLayout = "directory"
+)
+
+type convertCmd struct {
+	layout string
+}
+
+func NewConvertCommand() subcommands.Command { return &convertCmd{layout: bundleLayout} }
+
+func (c *convertCmd) Name() string { return "convert-layout" }
+
+func (c *convertCmd) Synopsis() string { return "convert environment storage layout" }
+
+func (c *convertCmd) Usage() string {
+	return `untold convert-layout [-to={bundle|directory}] <environment_name>:
+  Move secrets of environment from directory with file per secret to single {environment_name}.untold bundle file
+  or back. Secret files are moved as they are, decryption key is not needed.
+`
+}
+
+func (c *convertCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.layout, "to", c.layout, "set target layout: bundle or directory")
+}
+
+func (c *convertCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	environmentName := f.Arg(0)
+	if environmentName == "" {
+		cli.Errorf("argument \"environment_name\" is required")
+		c.Usage()
+
+		return subcommands.ExitUsageError
+	}
+
+	if c.layout != bundleLayout && c.layout != directoryLayout {
+		cli.Errorf("unknown layout %q", c.layout)
+
+		return subcommands.ExitUsageError
+	}
+
+	storage, err := cli.OpenStorage(environmentName)
+	if err != nil {
+		cli.Wrapf(err, "open %q environment", environmentName)
+
+		return subcommands.ExitUsageError
+	}
+
+	_, err = os.Stat(cli.BundlePath(environmentName))
+	if bundled := err == nil; bundled == (c.layout == bundleLayout) {
+		cli.Errorf("%q environment is already stored in %s layout", environmentName, c.layout)
+
+		return subcommands.ExitUsageError
+	}
+
+	filenames, err := storage.Files()
+	if err != nil {
+		cli.Wrapf(err, "list files of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	files := make(map[string][]byte, len(filenames))
+	for _, filename := range filenames {
+		files[filename], err = storage.ReadFile(filename)
+		if err != nil {
+			cli.Wrapf(err, "read %q file of %q environment", filename, environmentName)
+
+			return subcommands.ExitFailure
+		}
+	}
+
+	if c.layout == bundleLayout {
+		return toBundle(environmentName, files)
+	}
+
+	return toDirectory(environmentName, files)
+}
+
+// toBundle writes files of the environment to bundle file and removes environment directory.
+func toBundle(environmentName string, files map[string][]byte) subcommands.ExitStatus {
+	content, err := untold.FormatBundle(files)
+	if err != nil {
+		cli.Wrapf(err, "format bundle of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	if err := os.WriteFile(cli.BundlePath(environmentName), content, 0644); err != nil {
+		cli.Wrapf(err, "write bundle file of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	for filename := range files {
+		if err := os.Remove(filepath.Join(environmentName, filename)); err != nil {
+			cli.Wrapf(err, "remove %q file of %q environment", filename, environmentName)
+
+			return subcommands.ExitFailure
+		}
+	}
+
+	if err := os.Remove(filepath.Join(environmentName, ".gitkeep")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		cli.Wrapf(err, "remove .gitkeep file of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	if err := os.Remove(environmentName); err != nil {
+		cli.Wrapf(err, "remove directory %q", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	cli.Successf("Secrets of %q environment moved to %q.", environmentName, cli.BundlePath(environmentName))
+
+	return subcommands.ExitSuccess
+}
+
+// toDirectory writes files of the environment to environment directory and removes bundle file.
+func toDirectory(environmentName string, files map[string][]byte) subcommands.ExitStatus {
+	if status := createDirectory(environmentName); status != subcommands.ExitSuccess {
+		return status
+	}
+
+	for filename, content := range files {
+		if err := os.WriteFile(filepath.Join(environmentName, filename), content, 0644); err != nil {
+			cli.Wrapf(err, "write %q file of %q environment", filename, environmentName)
+
+			return subcommands.ExitFailure
+		}
+	}
+
+	if err := os.Remove(cli.BundlePath(environmentName)); err != nil {
+		cli.Wrapf(err, "remove bundle file of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	cli.Successf("Secrets of %q environment moved to %q directory.", environmentName, environmentName)
+
+	return subcommands.ExitSuccess
+}
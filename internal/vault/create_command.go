@@ -12,7 +12,9 @@ import (
 	"path/filepath"
 )
 
-type createCmd struct {}
+type createCmd struct {
+	passphrase, bundle bool
+}
 
 func NewCreateCommand() subcommands.Command { return &createCmd{} }
 
@@ -21,14 +23,17 @@ func (c *createCmd) Name() string { return "new-env" }
 func (c *createCmd) Synopsis() string { return "create new environment" }
 
 func (c *createCmd) Usage() string {
-	return `untold new-env <environment_name>:
-  Create new environment.
+	return `untold new-env [-passphrase] [-bundle] <environment_name>:
+  Create new environment. With -bundle flag secrets of environment are stored in single {environment_name}.untold file.
 `
 }
 
-func (c createCmd) SetFlags(f *flag.FlagSet) {}
+func (c *createCmd) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&c.passphrase, "passphrase", c.passphrase, "encrypt private key with passphrase")
+	f.BoolVar(&c.bundle, "bundle", c.bundle, "store secrets in single bundle file")
+}
 
-func (c createCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+func (c *createCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
 	environmentName := f.Arg(0)
 	if environmentName == "" {
 		cli.Errorf("argument \"environment_name\" is required")
@@ -43,6 +48,12 @@ func (c createCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interfa
 		return subcommands.ExitUsageError
 	}
 
+	if _, err := os.Stat(cli.BundlePath(environmentName)); !os.IsNotExist(err) {
+		cli.Errorf("file %q already exists", cli.BundlePath(environmentName))
+
+		return subcommands.ExitUsageError
+	}
+
 	if _, err := os.Stat(environmentName+".public"); !os.IsNotExist(err) {
 		cli.Errorf("file %q already exists", environmentName+".public")
 
@@ -55,16 +66,14 @@ func (c createCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interfa
 		return subcommands.ExitUsageError
 	}
 
-	if err := os.Mkdir(environmentName, 0755); err != nil {
-		cli.Wrapf(err, "create directory %q", environmentName)
-
-		return subcommands.ExitFailure
-	}
-
-	if err := os.WriteFile(filepath.Join(environmentName, ".gitkeep"), []byte("*"), 0644); err != nil {
-		cli.Wrapf(err, "create .gitkeep file for %q environment", environmentName)
+	if c.bundle {
+		if _, err := cli.CreateBundle(environmentName); err != nil {
+			cli.Wrapf(err, "create bundle file for %q environment", environmentName)
 
-		return subcommands.ExitFailure
+			return subcommands.ExitFailure
+		}
+	} else if status := createDirectory(environmentName); status != subcommands.ExitSuccess {
+		return status
 	}
 
 	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
@@ -80,7 +89,24 @@ func (c createCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interfa
 		return subcommands.ExitFailure
 	}
 
-	if err := os.WriteFile(filepath.Join(environmentName+".private"), untold.Base64Encode(privateKey[:]), 0644); err != nil {
+	privateKeyContent := untold.Base64Encode(privateKey[:])
+	if c.passphrase {
+		passphrase, err := cli.NewPassphrase(environmentName)
+		if err != nil {
+			cli.Wrapf(err, "read passphrase")
+
+			return subcommands.ExitFailure
+		}
+
+		privateKeyContent, err = untold.EncryptPrivateKey(*privateKey, passphrase)
+		if err != nil {
+			cli.Wrapf(err, "encrypt private key for environment %q", environmentName)
+
+			return subcommands.ExitFailure
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(environmentName+".private"), privateKeyContent, 0600); err != nil {
 		cli.Wrapf(err, "write private key for environment %q", environmentName)
 
 		return subcommands.ExitFailure
@@ -90,3 +116,20 @@ func (c createCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interfa
 
 	return subcommands.ExitSuccess
 }
+
+// createDirectory creates environment directory, kept in git by .gitkeep file.
+func createDirectory(environmentName string) subcommands.ExitStatus {
+	if err := os.Mkdir(environmentName, 0755); err != nil {
+		cli.Wrapf(err, "create directory %q", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	if err := os.WriteFile(filepath.Join(environmentName, ".gitkeep"), []byte("*"), 0644); err != nil {
+		cli.Wrapf(err, "create .gitkeep file for %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	return subcommands.ExitSuccess
+}
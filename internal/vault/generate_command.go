@@ -0,0 +1,42 @@
+package vault
+
+import (
+	"context"
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"github.com/damejeras/untold"
+	"github.com/damejeras/untold/internal/cli"
+	"github.com/google/subcommands"
+	"golang.org/x/crypto/nacl/box"
+)
+
+type generateKeyCmd struct{}
+
+func NewGenerateKeyCommand() subcommands.Command { return &generateKeyCmd{} }
+
+func (g *generateKeyCmd) Name() string { return "generate-key" }
+
+func (g *generateKeyCmd) Synopsis() string { return "generate personal keypair" }
+
+func (g *generateKeyCmd) Usage() string {
+	return `untold generate-key:
+  Generate keypair. Share public key to be added as a recipient and keep private key to yourself.
+`
+}
+
+func (g *generateKeyCmd) SetFlags(f *flag.FlagSet) {}
+
+func (g *generateKeyCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		cli.Wrapf(err, "generate keypair")
+
+		return subcommands.ExitFailure
+	}
+
+	fmt.Printf("Public key: %s\n", untold.Base64Encode(publicKey[:]))
+	fmt.Printf("Private key: %s\n", untold.Base64Encode(privateKey[:]))
+
+	return subcommands.ExitSuccess
+}
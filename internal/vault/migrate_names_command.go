@@ -0,0 +1,155 @@
+package vault
+
+import (
+	"context"
+	"flag"
+	"github.com/damejeras/untold"
+	"github.com/damejeras/untold/internal/cli"
+	"github.com/google/subcommands"
+)
+
+type migrateNamesCmd struct {
+	privateKey string
+	md5        bool
+}
+
+func NewMigrateNamesCommand() subcommands.Command { return &migrateNamesCmd{} }
+
+func (m *migrateNamesCmd) Name() string { return "migrate-names" }
+
+func (m *migrateNamesCmd) Synopsis() string { return "rename secret files by keyed hash" }
+
+func (m *migrateNamesCmd) Usage() string {
+	return `untold migrate-names [-key={decryption_key}] [-md5] <environment_name> [secret_name ...]:
+  Rename secret files of environment from MD5 hash of secret's name to HMAC-SHA256 under random name key,
+  which is stored encrypted to environment keys. With -md5 flag files are renamed back to MD5 hash.
+  Names of secrets written before they were bound to their names must be given as arguments.
+`
+}
+
+func (m *migrateNamesCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&m.privateKey, "key", m.privateKey, "provide decryption key")
+	f.BoolVar(&m.md5, "md5", m.md5, "rename secret files back to MD5 hash of secret's name")
+}
+
+func (m *migrateNamesCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	environmentName := f.Arg(0)
+	if environmentName == "" {
+		cli.Errorf("argument \"environment_name\" is required")
+		m.Usage()
+
+		return subcommands.ExitUsageError
+	}
+
+	storage, err := cli.OpenStorage(environmentName)
+	if err != nil {
+		cli.Wrapf(err, "open %q environment", environmentName)
+
+		return subcommands.ExitUsageError
+	}
+
+	if cli.KeyedNames(storage) != m.md5 {
+		cli.Errorf("secret files of %q environment are already named by the requested hash", environmentName)
+
+		return subcommands.ExitUsageError
+	}
+
+	_, privateKey, err := cli.LoadKeys(environmentName, []byte(m.privateKey))
+	if err != nil {
+		cli.Wrapf(err, "load keys for %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	nameKey, err := cli.NameKey(storage, [][32]byte{privateKey})
+	if err != nil {
+		cli.Wrapf(err, "load name key for %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	secrets, err := readSecrets(storage, [][32]byte{privateKey})
+	if err != nil {
+		cli.Wrapf(err, "read secrets of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	delete(secrets, untold.NameKeyFile)
+
+	// names given as arguments resolve secrets, which are not bound to their names yet
+	for _, name := range f.Args()[1:] {
+		filename := untold.SecretFileName(name, nameKey)
+		if secret, ok := secrets[filename]; ok && secret.binding == (untold.Binding{}) {
+			secrets[filename] = storedSecret{value: secret.value, binding: untold.Binding{Name: name, Environment: environmentName}}
+		}
+	}
+
+	if unbound := countUnbound(secrets); unbound > 0 {
+		cli.Errorf("%d secrets of %q environment are not bound to their names, give their names as arguments", unbound, environmentName)
+
+		return subcommands.ExitUsageError
+	}
+
+	var newNameKey []byte
+	if !m.md5 {
+		newNameKey, err = untold.GenerateNameKey()
+		if err != nil {
+			cli.Wrapf(err, "generate name key for %q environment", environmentName)
+
+			return subcommands.ExitFailure
+		}
+	}
+
+	recipients, err := cli.RecipientKeys(environmentName)
+	if err != nil {
+		cli.Wrapf(err, "load public keys for %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	renamed := make(map[string]storedSecret, len(secrets))
+	for filename := range secrets {
+		renamed[untold.SecretFileName(secrets[filename].binding.Name, newNameKey)] = secrets[filename]
+	}
+
+	if err := writeSecrets(storage, renamed, recipients); err != nil {
+		cli.Wrapf(err, "write secrets of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	if _, err := rewriteIndex(storage, [][32]byte{privateKey}, secrets, recipients); err != nil {
+		cli.Wrapf(err, "write index of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	if m.md5 {
+		err = storage.Remove(untold.NameKeyFile)
+	} else {
+		var content []byte
+		content, err = untold.SealNameKey(newNameKey, environmentName, recipients)
+		if err == nil {
+			err = storage.WriteFile(untold.NameKeyFile, content)
+		}
+	}
+
+	if err != nil {
+		cli.Wrapf(err, "update name key of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	for filename := range secrets {
+		if err := storage.Remove(filename); err != nil {
+			cli.Wrapf(err, "remove %q file of %q environment", filename, environmentName)
+
+			return subcommands.ExitFailure
+		}
+	}
+
+	cli.Successf("%d secret files of %q environment renamed.", len(secrets), environmentName)
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,186 @@
+package vault
+
+import (
+	"context"
+	"flag"
+	"github.com/damejeras/untold"
+	"github.com/damejeras/untold/internal/cli"
+	"github.com/google/subcommands"
+	"os"
+)
+
+type addRecipientCmd struct {
+	privateKey string
+}
+
+func NewAddRecipientCommand() subcommands.Command { return &addRecipientCmd{} }
+
+func (a *addRecipientCmd) Name() string { return "add-recipient" }
+
+func (a *addRecipientCmd) Synopsis() string { return "add environment recipient" }
+
+func (a *addRecipientCmd) Usage() string {
+	return `untold add-recipient [-key={decryption_key}] <environment_name> <recipient_name> <public_key>:
+  Add recipient to environment and re-encrypt its secrets, so they can be decrypted with recipient's private key.
+`
+}
+
+func (a *addRecipientCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&a.privateKey, "key", a.privateKey, "provide decryption key")
+}
+
+func (a *addRecipientCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	environmentName, recipientName := f.Arg(0), f.Arg(1)
+	if environmentName == "" || recipientName == "" || f.Arg(2) == "" {
+		cli.Errorf("arguments \"environment_name\", \"recipient_name\" and \"public_key\" are required")
+		a.Usage()
+
+		return subcommands.ExitUsageError
+	}
+
+	publicKey, err := untold.DecodeBase64Key([]byte(f.Arg(2)))
+	if err != nil {
+		cli.Wrapf(err, "decode base64 encoded public key")
+
+		return subcommands.ExitUsageError
+	}
+
+	recipients, err := cli.LoadRecipients(environmentName)
+	if err != nil {
+		cli.Wrapf(err, "load recipients of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	for i := range recipients {
+		if recipients[i].Name == recipientName || recipients[i].PublicKey == publicKey {
+			cli.Errorf("recipient %q of %q environment already exists", recipients[i].Name, environmentName)
+
+			return subcommands.ExitUsageError
+		}
+	}
+
+	recipients = append(recipients, untold.Recipient{Name: recipientName, PublicKey: publicKey})
+	if status := updateRecipients(environmentName, []byte(a.privateKey), recipients); status != subcommands.ExitSuccess {
+		return status
+	}
+
+	cli.Successf("Recipient %q added to %q environment.", recipientName, environmentName)
+
+	return subcommands.ExitSuccess
+}
+
+type removeRecipientCmd struct {
+	privateKey string
+}
+
+func NewRemoveRecipientCommand() subcommands.Command { return &removeRecipientCmd{} }
+
+func (r *removeRecipientCmd) Name() string { return "remove-recipient" }
+
+func (r *removeRecipientCmd) Synopsis() string { return "remove environment recipient" }
+
+func (r *removeRecipientCmd) Usage() string {
+	return `untold remove-recipient [-key={decryption_key}] <environment_name> <recipient_name>:
+  Remove recipient from environment and re-encrypt its secrets without recipient's key.
+`
+}
+
+func (r *removeRecipientCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&r.privateKey, "key", r.privateKey, "provide decryption key")
+}
+
+func (r *removeRecipientCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	environmentName, recipientName := f.Arg(0), f.Arg(1)
+	if environmentName == "" || recipientName == "" {
+		cli.Errorf("arguments \"environment_name\" and \"recipient_name\" are required")
+		r.Usage()
+
+		return subcommands.ExitUsageError
+	}
+
+	recipients, err := cli.LoadRecipients(environmentName)
+	if err != nil {
+		cli.Wrapf(err, "load recipients of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	remaining := make([]untold.Recipient, 0, len(recipients))
+	for i := range recipients {
+		if recipients[i].Name != recipientName {
+			remaining = append(remaining, recipients[i])
+		}
+	}
+
+	if len(remaining) == len(recipients) {
+		cli.Errorf("recipient %q of %q environment not found", recipientName, environmentName)
+
+		return subcommands.ExitUsageError
+	}
+
+	if status := updateRecipients(environmentName, []byte(r.privateKey), remaining); status != subcommands.ExitSuccess {
+		return status
+	}
+
+	cli.Successf("Recipient %q removed from %q environment.", recipientName, environmentName)
+
+	return subcommands.ExitSuccess
+}
+
+// updateRecipients re-encrypts secrets of the environment to its key and given recipients,
+// then stores recipients in {environment}.recipients file.
+func updateRecipients(environmentName string, base64EncodedPrivateKey []byte, recipients []untold.Recipient) subcommands.ExitStatus {
+	storage, err := cli.OpenStorage(environmentName)
+	if err != nil {
+		cli.Wrapf(err, "open %q environment", environmentName)
+
+		return subcommands.ExitUsageError
+	}
+
+	_, privateKey, err := cli.LoadKeys(environmentName, base64EncodedPrivateKey)
+	if err != nil {
+		cli.Wrapf(err, "load keys for %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	publicKey, err := cli.LoadPublicKey(environmentName)
+	if err != nil {
+		cli.Wrapf(err, "load public key for %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	secrets, err := readSecrets(storage, [][32]byte{privateKey})
+	if err != nil {
+		cli.Wrapf(err, "read secrets of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	recipientKeys := [][32]byte{publicKey}
+	for i := range recipients {
+		recipientKeys = append(recipientKeys, recipients[i].PublicKey)
+	}
+
+	if err := writeSecrets(storage, secrets, recipientKeys); err != nil {
+		cli.Wrapf(err, "write secrets of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	if _, err := rewriteIndex(storage, [][32]byte{privateKey}, secrets, recipientKeys); err != nil {
+		cli.Wrapf(err, "write index of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	if err := os.WriteFile(environmentName+".recipients", untold.FormatRecipients(recipients), 0644); err != nil {
+		cli.Wrapf(err, "write recipients of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	return subcommands.ExitSuccess
+}
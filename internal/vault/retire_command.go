@@ -0,0 +1,74 @@
+package vault
+
+import (
+	"context"
+	"flag"
+	"github.com/damejeras/untold"
+	"github.com/damejeras/untold/internal/cli"
+	"github.com/google/subcommands"
+	"strings"
+)
+
+type retireCmd struct {
+	privateKey string
+}
+
+func NewRetireCommand() subcommands.Command { return &retireCmd{} }
+
+func (r *retireCmd) Name() string { return "retire-key" }
+
+func (r *retireCmd) Synopsis() string { return "retire old environment keys" }
+
+func (r *retireCmd) Usage() string {
+	return `untold retire-key [-key={decryption_key}] <environment_name> [key_id]:
+  Re-encrypt environment secrets without old keys kept valid by "rotate-keys -keep-old".
+  All old keys are retired unless key ID is given.
+`
+}
+
+func (r *retireCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&r.privateKey, "key", r.privateKey, "provide decryption key")
+}
+
+func (r *retireCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	environmentName, keyID := f.Arg(0), f.Arg(1)
+	if environmentName == "" {
+		cli.Errorf("argument \"environment_name\" is required")
+		r.Usage()
+
+		return subcommands.ExitUsageError
+	}
+
+	recipients, err := cli.LoadRecipients(environmentName)
+	if err != nil {
+		cli.Wrapf(err, "load recipients of %q environment", environmentName)
+
+		return subcommands.ExitFailure
+	}
+
+	var retired []string
+	remaining := make([]untold.Recipient, 0, len(recipients))
+	for i := range recipients {
+		if strings.HasPrefix(recipients[i].Name, retiringPrefix) && (keyID == "" || untold.KeyID(recipients[i].PublicKey) == keyID) {
+			retired = append(retired, untold.KeyID(recipients[i].PublicKey))
+
+			continue
+		}
+
+		remaining = append(remaining, recipients[i])
+	}
+
+	if len(retired) == 0 {
+		cli.Errorf("no old keys to retire in %q environment", environmentName)
+
+		return subcommands.ExitUsageError
+	}
+
+	if status := updateRecipients(environmentName, []byte(r.privateKey), remaining); status != subcommands.ExitSuccess {
+		return status
+	}
+
+	cli.Successf("Keys %q of %q environment retired.", retired, environmentName)
+
+	return subcommands.ExitSuccess
+}
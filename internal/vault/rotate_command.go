@@ -8,13 +8,15 @@ import (
 	"github.com/damejeras/untold/internal/cli"
 	"github.com/google/subcommands"
 	"golang.org/x/crypto/nacl/box"
-	"io/ioutil"
 	"os"
-	"path/filepath"
 )
 
+// retiringPrefix prefixes names of recipients holding old environment keys, which are still valid after rotation.
+const retiringPrefix = "retiring-"
+
 type rotateCmd struct {
-	privateKey string
+	privateKey          string
+	passphrase, keepOld bool
 }
 
 func NewRotateCommand() subcommands.Command { return &rotateCmd{}}
@@ -24,13 +26,17 @@ func (r *rotateCmd) Name() string { return "rotate-keys" }
 func (r *rotateCmd) Synopsis() string { return "rotate environment keys" }
 
 func (r *rotateCmd) Usage() string {
-	return `untold rotate-keys <environment_name>:
-  Rotate environment keys.
+	return `untold rotate-keys [-key={decryption_key}] [-passphrase] [-keep-old] <environment_name>:
+  Rotate environment keys. New private key is encrypted with passphrase if -passphrase
+  flag is set or the old private key was encrypted. With -keep-old flag secrets stay
+  encrypted to the old key as well, until it is retired with retire-key command.
 `
 }
 
 func (r *rotateCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&r.privateKey, "key", r.privateKey, "provide decryption key")
+	f.BoolVar(&r.passphrase, "passphrase", r.passphrase, "encrypt new private key with passphrase")
+	f.BoolVar(&r.keepOld, "keep-old", r.keepOld, "keep old key valid until it is retired")
 }
 
 func (r *rotateCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
@@ -44,34 +50,15 @@ func (r *rotateCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interf
 
 	base64EncodedPrivateKey := []byte(r.privateKey)
 
-	if _, err := os.Stat(environmentName); os.IsNotExist(err) {
-		cli.Errorf("directory for %q environment not found", environmentName)
-
-		return subcommands.ExitUsageError
-	}
-
-	if _, err := os.Stat(environmentName + ".public"); os.IsNotExist(err) {
-		cli.Errorf("public key for %q environment not found", environmentName)
-
-		return subcommands.ExitUsageError
-	}
-
-	if len(base64EncodedPrivateKey) == 0 {
-		if _, err := os.Stat(environmentName + ".private"); os.IsNotExist(err) {
-			cli.Errorf("private key for %q environment not found", environmentName)
-
-			return subcommands.ExitUsageError
-		}
-	}
-
-	base64EncodedPublicKey, err := os.ReadFile(environmentName + ".public")
+	storage, err := cli.OpenStorage(environmentName)
 	if err != nil {
-		cli.Wrapf(err, "read public key for %q environment", environmentName)
+		cli.Wrapf(err, "open %q environment", environmentName)
 
-		return subcommands.ExitFailure
+		return subcommands.ExitUsageError
 	}
 
 	if len(base64EncodedPrivateKey) == 0 {
+		var err error
 		base64EncodedPrivateKey, err = os.ReadFile(environmentName + ".private")
 		if err != nil {
 			cli.Wrapf(err, "read private key for %q environment", environmentName)
@@ -80,79 +67,83 @@ func (r *rotateCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interf
 		}
 	}
 
-	var publicKey, privateKey [32]byte
-
-	publicKey, err = untold.DecodeBase64Key(base64EncodedPublicKey)
+	_, privateKey, err := cli.LoadKeys(environmentName, base64EncodedPrivateKey)
 	if err != nil {
-		cli.Wrapf(err, "decode base64 encoded public key for %q environment", environmentName)
+		cli.Wrapf(err, "load keys for %q environment", environmentName)
 
 		return subcommands.ExitFailure
 	}
 
-	privateKey, err = untold.DecodeBase64Key(base64EncodedPrivateKey)
+	secrets, err := readSecrets(storage, [][32]byte{privateKey})
 	if err != nil {
-		cli.Wrapf(err, "decode base64 encoded private key for %q environment", environmentName)
+		cli.Wrapf(err, "read secrets of %q environment", environmentName)
 
 		return subcommands.ExitFailure
 	}
 
-	files, err := ioutil.ReadDir(environmentName)
+	recipients, err := cli.LoadRecipients(environmentName)
 	if err != nil {
-		cli.Wrapf(err, "read environment %q secrets", environmentName)
+		cli.Wrapf(err, "load recipients of %q environment", environmentName)
 
 		return subcommands.ExitFailure
 	}
 
-	values := make(map[string]string)
-
-	for _, file := range files {
-		filename := file.Name()
-		if filename == ".gitkeep" {
-			continue
-		}
-
-		base64EncodedSecret, err := os.ReadFile(filepath.Join(environmentName, file.Name()))
+	if r.keepOld {
+		oldPublicKey, err := cli.LoadPublicKey(environmentName)
 		if err != nil {
-			cli.Wrapf(err, "read %q file", filepath.Join(environmentName, file.Name()))
+			cli.Wrapf(err, "load public key for %q environment", environmentName)
 
 			return subcommands.ExitFailure
 		}
 
-		decodedSecret, err := untold.Base64Decode(base64EncodedSecret)
+		recipients = append(recipients, untold.Recipient{Name: retiringPrefix + untold.KeyID(oldPublicKey), PublicKey: oldPublicKey})
+	}
+
+	newPublicKey, newPrivateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		cli.Wrapf(err, "generate new keypair")
+
+		return subcommands.ExitFailure
+	}
+
+	newPrivateKeyContent := untold.Base64Encode(newPrivateKey[:])
+	if r.passphrase || untold.IsEncryptedPrivateKey(base64EncodedPrivateKey) {
+		passphrase, err := cli.NewPassphrase(environmentName)
 		if err != nil {
-			cli.Wrapf(err, "decode secret %q", file.Name())
+			cli.Wrapf(err, "read passphrase")
 
 			return subcommands.ExitFailure
 		}
 
-		decryptedSecret, ok := box.OpenAnonymous(nil, decodedSecret, &publicKey, &privateKey)
-		if !ok {
-			cli.Errorf("can not decode secret %q", file.Name())
+		newPrivateKeyContent, err = untold.EncryptPrivateKey(*newPrivateKey, passphrase)
+		if err != nil {
+			cli.Wrapf(err, "encrypt new private key")
 
 			return subcommands.ExitFailure
 		}
+	}
 
-		values[file.Name()] = string(decryptedSecret)
+	recipientKeys := [][32]byte{*newPublicKey}
+	for i := range recipients {
+		recipientKeys = append(recipientKeys, recipients[i].PublicKey)
 	}
 
-	newPublicKey, newPrivateKey, err := box.GenerateKey(rand.Reader)
-	if err != nil {
-		cli.Wrapf(err, "generate new keypair")
+	if err := writeSecrets(storage, secrets, recipientKeys); err != nil {
+		cli.Wrapf(err, "write secrets of %q environment", environmentName)
 
 		return subcommands.ExitFailure
 	}
 
-	for filename, value := range values {
-		encryptedValue, err := box.SealAnonymous(nil, []byte(value), newPublicKey, rand.Reader)
-		if err != nil {
-			cli.Wrapf(err, "encrypt %q value", filename)
+	names, err := rewriteIndex(storage, [][32]byte{privateKey}, secrets, recipientKeys)
+	if err != nil {
+		cli.Wrapf(err, "write index of %q environment", environmentName)
 
-			return subcommands.ExitFailure
-		}
+		return subcommands.ExitFailure
+	}
 
-		err = os.WriteFile(filepath.Join(environmentName, filename), untold.Base64Encode(encryptedValue), 0644)
-		if err != nil {
-			cli.Wrapf(err, "write encrypted value to %q", filename)
+	if r.keepOld {
+		if err := os.WriteFile(environmentName+".recipients", untold.FormatRecipients(recipients), 0644); err != nil {
+			cli.Wrapf(err, "write recipients of %q environment", environmentName)
 
 			return subcommands.ExitFailure
 		}
@@ -164,13 +155,21 @@ func (r *rotateCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interf
 		return subcommands.ExitFailure
 	}
 
-	if err := os.WriteFile(environmentName+".private", untold.Base64Encode(newPrivateKey[:]), 0644); err != nil {
+	if err := os.WriteFile(environmentName+".private", newPrivateKeyContent, 0600); err != nil {
 		cli.Wrapf(err, "write new private key")
 
 		return subcommands.ExitFailure
 	}
 
-	cli.Successf("Keys for environment %q rotated", environmentName)
+	if unbound := countUnbound(secrets); unbound > 0 {
+		cli.Warnf("%d secrets of %q environment are not bound to their names, bind them by changing with change-secret", unbound, environmentName)
+	}
+
+	if r.keepOld {
+		cli.Warnf("Old key %q stays valid until it is retired with \"untold retire-key %s\"", untold.KeyID(recipients[len(recipients)-1].PublicKey), environmentName)
+	}
+
+	cli.Successf("Keys for environment %q rotated, new key is %q. Secrets re-encrypted: %q", environmentName, untold.KeyID(*newPublicKey), names)
 
 	return subcommands.ExitSuccess
 }
@@ -0,0 +1,115 @@
+package vault
+
+import (
+	"fmt"
+	"github.com/damejeras/untold"
+	"github.com/damejeras/untold/internal/cli"
+)
+
+// storedSecret is decrypted secret together with the name and environment it is bound to.
+type storedSecret struct {
+	value   []byte
+	binding untold.Binding
+}
+
+// readSecrets decrypts every secret of the environment with any of private keys. Secrets are keyed by file name,
+// name key of keyed secret file names is read as well. Secret bound to other environment or to name not matching
+// its file name is reported as tampered.
+func readSecrets(storage cli.Storage, privateKeys [][32]byte) (map[string]storedSecret, error) {
+	files, err := storage.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	nameKey, err := cli.NameKey(storage, privateKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	environment := storage.Environment()
+	secrets := make(map[string]storedSecret)
+
+	for _, file := range files {
+		if file == untold.IndexFile {
+			continue
+		}
+
+		content, err := storage.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read %q file: %w", file, err)
+		}
+
+		value, binding, err := untold.OpenSecretBinding(content, privateKeys)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt secret %q: %w", file, err)
+		}
+
+		if binding != (untold.Binding{}) && (binding.Environment != environment || secretFileName(binding.Name, nameKey) != file) {
+			return nil, fmt.Errorf("%w: file %q holds secret %q of %q environment", untold.ErrTampered, file, binding.Name, binding.Environment)
+		}
+
+		secrets[file] = storedSecret{value: value, binding: binding}
+	}
+
+	return secrets, nil
+}
+
+// writeSecrets encrypts secrets of the environment to every recipient and writes them to files.
+// Secrets stay bound to their names and environment.
+func writeSecrets(storage cli.Storage, secrets map[string]storedSecret, recipients [][32]byte) error {
+	for filename, secret := range secrets {
+		encryptedValue, err := untold.SealSecret(secret.value, secret.binding, recipients)
+		if err != nil {
+			return fmt.Errorf("encrypt %q value: %w", filename, err)
+		}
+
+		if err := storage.WriteFile(filename, encryptedValue); err != nil {
+			return fmt.Errorf("write encrypted value to %q: %w", filename, err)
+		}
+	}
+
+	return nil
+}
+
+// rewriteIndex replaces encrypted index of the environment with names listed in the current index and names
+// secrets are bound to, encrypted to every recipient. Private keys must open the current index.
+func rewriteIndex(storage cli.Storage, privateKeys [][32]byte, secrets map[string]storedSecret, recipients [][32]byte) ([]string, error) {
+	names, err := cli.ReadIndex(storage, privateKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	for filename := range secrets {
+		if name := secrets[filename].binding.Name; name != "" && name != untold.NameKeyFile {
+			names = append(names, name)
+		}
+	}
+
+	names = untold.SortNames(names)
+	if err := cli.WriteIndex(storage, names, recipients); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// secretFileName returns name of the file holding secret, NameKeyFile holds name key itself.
+func secretFileName(name string, nameKey []byte) string {
+	if name == untold.NameKeyFile {
+		return untold.NameKeyFile
+	}
+
+	return untold.SecretFileName(name, nameKey)
+}
+
+// countUnbound returns number of secrets, which are not bound to their names.
+func countUnbound(secrets map[string]storedSecret) int {
+	var count int
+	for filename := range secrets {
+		if secrets[filename].binding == (untold.Binding{}) {
+			count++
+		}
+	}
+
+	return count
+}
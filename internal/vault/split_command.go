@@ -0,0 +1,126 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/damejeras/untold"
+	"github.com/damejeras/untold/internal/cli"
+	"github.com/google/subcommands"
+	"os"
+	"path/filepath"
+)
+
+type splitKeyCmd struct {
+	environment, privateKey, holders, output string
+	shares, threshold                        int
+}
+
+func NewSplitKeyCommand() subcommands.Command {
+	return &splitKeyCmd{environment: untold.DefaultEnvironment, output: ".", shares: 5, threshold: 3}
+}
+
+func (s *splitKeyCmd) Name() string { return "split-key" }
+
+func (s *splitKeyCmd) Synopsis() string { return "split private key into shares" }
+
+func (s *splitKeyCmd) Usage() string {
+	return `untold split-key [-env={environment}] [-key={decryption_key}] [-shares=5] [-threshold=3] [-holders={recipients_file}] [-out={directory}]:
+  Split environment private key into shares, any threshold of which rebuild the key with combine-key command.
+  With -holders flag each share is encrypted to public key of the holder on the same line of recipients file.
+`
+}
+
+func (s *splitKeyCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.environment, "env", s.environment, "set environment")
+	f.StringVar(&s.privateKey, "key", s.privateKey, "provide private key to split")
+	f.IntVar(&s.shares, "shares", s.shares, "set number of shares")
+	f.IntVar(&s.threshold, "threshold", s.threshold, "set number of shares required to rebuild the key")
+	f.StringVar(&s.holders, "holders", s.holders, "encrypt shares to holders listed in recipients file")
+	f.StringVar(&s.output, "out", s.output, "set directory for share files")
+}
+
+func (s *splitKeyCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	environment := s.environment
+	if environment == "" || environment == untold.DefaultEnvironment {
+		environment = untold.DefaultEnvironment
+		cli.Warnf("No environment provided, using default - %q", environment)
+	}
+
+	privateKeyContent := []byte(s.privateKey)
+	if len(privateKeyContent) == 0 {
+		var err error
+		privateKeyContent, err = os.ReadFile(environment + ".private")
+		if err != nil {
+			cli.Wrapf(err, "read private key for %q environment", environment)
+
+			return subcommands.ExitFailure
+		}
+	}
+
+	privateKeyContent = bytes.TrimSpace(privateKeyContent)
+	if _, _, err := cli.LoadKeys(environment, privateKeyContent); err != nil {
+		cli.Wrapf(err, "load keys for %q environment", environment)
+
+		return subcommands.ExitFailure
+	}
+
+	var holders []untold.Recipient
+	if s.holders != "" {
+		content, err := os.ReadFile(s.holders)
+		if err != nil {
+			cli.Wrapf(err, "read holders file")
+
+			return subcommands.ExitFailure
+		}
+
+		holders, err = untold.ParseRecipients(content)
+		if err != nil {
+			cli.Wrapf(err, "parse holders file")
+
+			return subcommands.ExitFailure
+		}
+
+		if len(holders) != s.shares {
+			cli.Errorf("%d holders listed for %d shares", len(holders), s.shares)
+
+			return subcommands.ExitUsageError
+		}
+	}
+
+	shares, err := untold.SplitKey(environment, privateKeyContent, s.shares, s.threshold)
+	if err != nil {
+		cli.Wrapf(err, "split private key for %q environment", environment)
+
+		return subcommands.ExitUsageError
+	}
+
+	files := make([]string, len(shares))
+	for i := range shares {
+		var recipient *[32]byte
+		files[i] = filepath.Join(s.output, fmt.Sprintf("%s-%d.share", environment, shares[i].Index))
+		if holders != nil {
+			recipient = &holders[i].PublicKey
+			files[i] = filepath.Join(s.output, fmt.Sprintf("%s-%d-%s.share", environment, shares[i].Index, holders[i].Name))
+		}
+
+		armored, err := untold.ArmorKeyShare(shares[i], recipient)
+		if err != nil {
+			cli.Wrapf(err, "armor share %d", shares[i].Index)
+
+			return subcommands.ExitFailure
+		}
+
+		if err := os.WriteFile(files[i], armored, 0600); err != nil {
+			cli.Wrapf(err, "write share %d", shares[i].Index)
+
+			return subcommands.ExitFailure
+		}
+	}
+
+	cli.Warnf("Remove %q once shares are handed out to their holders", environment+".private")
+	cli.Successf("Private key of %q environment split into %d shares, %d of them rebuild the key: %q", environment, s.shares, s.threshold, files)
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,156 @@
+package untold
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// environmentPlaceholder is replaced with environment name in names, paths and arguments of key providers.
+const environmentPlaceholder = "{environment}"
+
+// ErrNoKey is returned by KeyProvider which has no key for the environment.
+// Next provider of the chain is tried then.
+var ErrNoKey = errors.New("key not provided")
+
+// KeyProvider provides base64 encoded private key of the environment.
+// Files are vault files rooted at the path prefix. Vault wipes returned bytes once the key is decoded,
+// so provider must return a fresh slice on every call.
+type KeyProvider interface {
+	PrivateKey(files fs.FS, environment string) ([]byte, error)
+}
+
+// KeyProviderFunc is an adapter to allow the use of ordinary functions as key providers.
+type KeyProviderFunc func(files fs.FS, environment string) ([]byte, error)
+
+func (f KeyProviderFunc) PrivateKey(files fs.FS, environment string) ([]byte, error) {
+	return f(files, environment)
+}
+
+// EnvKey provides key from environment variable.
+func EnvKey(name string) KeyProvider {
+	return KeyProviderFunc(func(_ fs.FS, environment string) ([]byte, error) {
+		value := os.Getenv(strings.ReplaceAll(name, environmentPlaceholder, environment))
+		if value == "" {
+			return nil, ErrNoKey
+		}
+
+		return []byte(value), nil
+	})
+}
+
+// FileKey provides key from file at given path. Key is not provided if file does not exist.
+func FileKey(path string) KeyProvider {
+	return KeyProviderFunc(func(_ fs.FS, environment string) ([]byte, error) {
+		content, err := os.ReadFile(strings.ReplaceAll(path, environmentPlaceholder, environment))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, ErrNoKey
+			}
+
+			return nil, err
+		}
+
+		return content, nil
+	})
+}
+
+// CredentialsKey provides key from untold-{environment} file in directory of systemd credentials
+// ($CREDENTIALS_DIRECTORY), passed to the service with LoadCredential= or SetCredentialEncrypted=.
+func CredentialsKey() KeyProvider {
+	return KeyProviderFunc(func(files fs.FS, environment string) ([]byte, error) {
+		directory := os.Getenv("CREDENTIALS_DIRECTORY")
+		if directory == "" {
+			return nil, ErrNoKey
+		}
+
+		return FileKey(filepath.Join(directory, "untold-"+environment)).PrivateKey(files, environment)
+	})
+}
+
+// DockerSecretKey provides key from /run/secrets/untold_{environment}, where Docker, Swarm
+// and Kubernetes (when configured so) mount secrets.
+func DockerSecretKey() KeyProvider {
+	return FileKey("/run/secrets/untold_" + environmentPlaceholder)
+}
+
+// FDKey provides key read from open file descriptor, e.g. a pipe set up by the parent process.
+// Descriptor is read once, the same key is provided for every environment.
+func FDKey(fd uintptr) KeyProvider {
+	var (
+		once    sync.Once
+		content []byte
+		err     error
+	)
+
+	return KeyProviderFunc(func(_ fs.FS, _ string) ([]byte, error) {
+		once.Do(func() {
+			file := os.NewFile(fd, "untold-key")
+			if file == nil {
+				err = fmt.Errorf("invalid file descriptor %d", fd)
+
+				return
+			}
+
+			defer file.Close()
+
+			content, err = io.ReadAll(file)
+		})
+
+		return append([]byte(nil), content...), err
+	})
+}
+
+// EmbeddedKey provides key from {environment}.private file stored next to the secrets.
+func EmbeddedKey() KeyProvider {
+	return KeyProviderFunc(func(files fs.FS, environment string) ([]byte, error) {
+		content, err := fs.ReadFile(files, environment+".private")
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, ErrNoKey
+			}
+
+			return nil, err
+		}
+
+		return content, nil
+	})
+}
+
+// CommandKey provides key printed to stdout by external command, e.g. CommandKey("pass", "show", "untold/{environment}").
+// Key is not provided if command is not installed or prints nothing. Command failing to run is an error.
+func CommandKey(name string, args ...string) KeyProvider {
+	return KeyProviderFunc(func(_ fs.FS, environment string) ([]byte, error) {
+		commandArgs := make([]string, len(args))
+		for i := range args {
+			commandArgs[i] = strings.ReplaceAll(args[i], environmentPlaceholder, environment)
+		}
+
+		var stdout bytes.Buffer
+		command := exec.Command(name, commandArgs...)
+		command.Stdout = &stdout
+		command.Stderr = os.Stderr
+
+		err := command.Run()
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNoKey
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("run %q: %w", name, err)
+		}
+
+		if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+			return nil, ErrNoKey
+		}
+
+		return stdout.Bytes(), nil
+	})
+}
@@ -0,0 +1,199 @@
+package untold
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyProvidersChain(t *testing.T) {
+	privateKey, err := testFS.ReadFile("test/test.private")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "test.key")
+	if err := os.WriteFile(keyFile, append(privateKey, '\n'), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var asked []string
+	record := func(name string) KeyProvider {
+		return KeyProviderFunc(func(_ fs.FS, environment string) ([]byte, error) {
+			asked = append(asked, name)
+
+			return nil, ErrNoKey
+		})
+	}
+
+	files := testFiles(t, "test/test.public", "test/test/098f6bcd4621d373cade4e832627b4f6")
+
+	v := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), KeyProviders(
+		record("first"),
+		EnvKey("UNTOLD_TEST_DOES_NOT_EXIST"),
+		FileKey(filepath.Join(filepath.Dir(keyFile), "{environment}.key")),
+		record("never"),
+	))
+
+	value, err := v.Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "test" {
+		t.Errorf("expected %q, got %q", "test", value)
+	}
+
+	if len(asked) != 1 || asked[0] != "first" {
+		t.Errorf("expected only first provider to be asked, got %v", asked)
+	}
+}
+
+func TestKeyProvidersNoKey(t *testing.T) {
+	v := NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), KeyProviders(EnvKey("UNTOLD_TEST_DOES_NOT_EXIST")))
+
+	if _, err := v.Get("test"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("expected ErrNoKey, got %v", err)
+	}
+}
+
+func TestKeyProvidersError(t *testing.T) {
+	failure := errors.New("failure")
+	v := NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), KeyProviders(
+		KeyProviderFunc(func(fs.FS, string) ([]byte, error) { return nil, failure }),
+		EmbeddedKey(),
+	))
+
+	if _, err := v.Get("test"); !errors.Is(err, failure) {
+		t.Errorf("expected provider error, got %v", err)
+	}
+}
+
+func TestCommandKey(t *testing.T) {
+	privateKey, err := testFS.ReadFile("test/test.private")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), KeyProviders(
+		CommandKey("echo", string(privateKey)),
+	))
+
+	value, err := v.Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "test" {
+		t.Errorf("expected %q, got %q", "test", value)
+	}
+}
+
+func TestCommandKeyNotFound(t *testing.T) {
+	v := NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), KeyProviders(
+		CommandKey("untold-test-does-not-exist", "{environment}"),
+		CommandKey(filepath.Join(t.TempDir(), "does-not-exist")),
+		EmbeddedKey(),
+	))
+
+	if _, err := v.Get("test"); err != nil {
+		t.Fatalf("expected missing commands to be skipped, got %v", err)
+	}
+
+	v = NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), KeyProviders(
+		CommandKey("false"),
+		EmbeddedKey(),
+	))
+
+	if _, err := v.Get("test"); err == nil || errors.Is(err, ErrNoKey) {
+		t.Errorf("expected error of failed command, got %v", err)
+	}
+}
+
+func TestFDKey(t *testing.T) {
+	privateKey, err := testFS.ReadFile("test/test.private")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write(privateKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// descriptor is owned and closed by the provider
+	provider := FDKey(r.Fd())
+
+	value, err := NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), KeyProviders(provider)).Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "test" {
+		t.Errorf("expected %q, got %q", "test", value)
+	}
+
+	// descriptor is read once, every environment gets the same key
+	key, err := provider.PrivateKey(nil, "shared")
+	if err != nil || string(key) != string(privateKey) {
+		t.Errorf("expected the same key for other environment, got %q, %v", key, err)
+	}
+}
+
+func TestDefaultKeyFileProviders(t *testing.T) {
+	privateKey, err := testFS.ReadFile("test/test.private")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := testFiles(t, "test/test.public", "test/test/098f6bcd4621d373cade4e832627b4f6")
+
+	directory := t.TempDir()
+	keyContent := append(append([]byte("  "), privateKey...), '\n')
+
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		options []Option
+	}{
+		{name: "env file", file: "key", env: map[string]string{"UNTOLD_TEST_KEY_FILE": filepath.Join(directory, "key")}},
+		{name: "key file option", file: "test.key", options: []Option{KeyFile(filepath.Join(directory, "{environment}.key"))}},
+		{name: "systemd credentials", file: "untold-test", env: map[string]string{"CREDENTIALS_DIRECTORY": directory}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := os.WriteFile(filepath.Join(directory, test.file), keyContent, 0600); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(filepath.Join(directory, test.file))
+
+			for key, value := range test.env {
+				os.Setenv(key, value)
+				defer os.Unsetenv(key)
+			}
+
+			options := append([]Option{Environment("test"), PathPrefix("test"), EnvVariable("UNTOLD_TEST_KEY")}, test.options...)
+
+			value, err := NewVault(files, options...).Get("test")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value != "test" {
+				t.Errorf("expected %q, got %q", "test", value)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package untold
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/nacl/box"
+	"strconv"
+	"strings"
+)
+
+const (
+	keyShareBegin = "-----BEGIN UNTOLD KEY SHARE-----"
+	keyShareEnd   = "-----END UNTOLD KEY SHARE-----"
+
+	keyShareEnvironmentHeader = "Environment"
+	keyShareIndexHeader       = "Share"
+	keyShareThresholdHeader   = "Threshold"
+	keyShareRecipientHeader   = "Encrypted-To"
+)
+
+// ErrNotEnoughShares is returned when less key shares than the threshold are combined.
+var ErrNotEnoughShares = errors.New("not enough key shares")
+
+// KeyShare is a part of environment's private key split with Shamir's secret sharing.
+// Any Threshold shares of the same split rebuild the key, fewer reveal nothing about it.
+type KeyShare struct {
+	Environment      string
+	Index, Threshold int
+	Value            []byte
+}
+
+// SplitKey splits private key into given number of shares, any threshold of which rebuild the key.
+func SplitKey(environment string, key []byte, shares, threshold int) ([]KeyShare, error) {
+	if threshold < 2 || threshold > shares || shares > 255 {
+		return nil, fmt.Errorf("invalid threshold %d of %d shares", threshold, shares)
+	}
+
+	result := make([]KeyShare, shares)
+	for i := range result {
+		result[i] = KeyShare{Environment: environment, Index: i + 1, Threshold: threshold, Value: make([]byte, len(key))}
+	}
+
+	coefficients := make([]byte, threshold)
+	for position := range key {
+		// polynomial of degree threshold-1 with the key byte as its constant term
+		coefficients[0] = key[position]
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, fmt.Errorf("generate polynomial: %w", err)
+		}
+
+		for i := range result {
+			result[i].Value[position] = evaluatePolynomial(coefficients, byte(result[i].Index))
+		}
+	}
+
+	wipe(coefficients)
+
+	return result, nil
+}
+
+// CombineKey rebuilds private key from key shares.
+func CombineKey(shares []KeyShare) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+
+	seen := make(map[int]bool)
+	for i := range shares {
+		switch {
+		case shares[i].Environment != shares[0].Environment:
+			return nil, fmt.Errorf("share %d belongs to %q environment, not %q", shares[i].Index, shares[i].Environment, shares[0].Environment)
+		case shares[i].Threshold != shares[0].Threshold || len(shares[i].Value) != len(shares[0].Value):
+			return nil, fmt.Errorf("share %d belongs to different split", shares[i].Index)
+		case shares[i].Index < 1 || shares[i].Index > 255:
+			return nil, fmt.Errorf("invalid share index %d", shares[i].Index)
+		case seen[shares[i].Index]:
+			return nil, fmt.Errorf("duplicate share %d", shares[i].Index)
+		}
+
+		seen[shares[i].Index] = true
+	}
+
+	if len(shares) < shares[0].Threshold {
+		return nil, fmt.Errorf("%w: %d of %d", ErrNotEnoughShares, len(shares), shares[0].Threshold)
+	}
+
+	key := make([]byte, len(shares[0].Value))
+	for i := range shares {
+		// Lagrange basis polynomial of share i evaluated at zero
+		numerator, denominator := byte(1), byte(1)
+		for j := range shares {
+			if i == j {
+				continue
+			}
+
+			numerator = gfMultiply(numerator, byte(shares[j].Index))
+			denominator = gfMultiply(denominator, byte(shares[i].Index)^byte(shares[j].Index))
+		}
+
+		basis := gfMultiply(numerator, gfInverse(denominator))
+		for position := range key {
+			key[position] ^= gfMultiply(shares[i].Value[position], basis)
+		}
+	}
+
+	return key, nil
+}
+
+// ArmorKeyShare formats key share as text. Share is encrypted to recipient's public key, unless recipient is nil.
+func ArmorKeyShare(share KeyShare, recipient *[32]byte) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	buffer.WriteString(keyShareBegin + "\n")
+	fmt.Fprintf(&buffer, "%s: %s\n", keyShareEnvironmentHeader, share.Environment)
+	fmt.Fprintf(&buffer, "%s: %d\n", keyShareIndexHeader, share.Index)
+	fmt.Fprintf(&buffer, "%s: %d\n", keyShareThresholdHeader, share.Threshold)
+
+	payload := share.Value
+	if recipient != nil {
+		encrypted, err := box.SealAnonymous(nil, share.Value, recipient, rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt key share: %w", err)
+		}
+
+		fmt.Fprintf(&buffer, "%s: %s\n", keyShareRecipientHeader, KeyID(*recipient))
+		payload = encrypted
+	}
+
+	buffer.WriteString("\n")
+	encoded := Base64Encode(payload)
+	for len(encoded) > 64 {
+		buffer.Write(encoded[:64])
+		buffer.WriteString("\n")
+		encoded = encoded[64:]
+	}
+
+	buffer.Write(encoded)
+	buffer.WriteString("\n" + keyShareEnd + "\n")
+
+	return buffer.Bytes(), nil
+}
+
+// IsEncryptedKeyShare reports whether armored key share is encrypted to recipient's public key.
+func IsEncryptedKeyShare(armored []byte) bool {
+	return bytes.Contains(armored, []byte("\n"+keyShareRecipientHeader+": "))
+}
+
+// ParseKeyShare parses armored key share. Encrypted share is decrypted with the first private key matching its recipient.
+func ParseKeyShare(armored []byte, privateKeys [][32]byte) (KeyShare, error) {
+	var (
+		share                     KeyShare
+		recipient                 string
+		payload                   strings.Builder
+		begun, headersRead, ended bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(armored))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case !begun:
+			begun = line == keyShareBegin
+		case line == keyShareEnd:
+			ended = true
+		case ended:
+		case !headersRead && line == "":
+			headersRead = true
+		case !headersRead:
+			name, value, err := splitHeader(line)
+			if err != nil {
+				return share, err
+			}
+
+			switch name {
+			case keyShareEnvironmentHeader:
+				share.Environment = value
+			case keyShareIndexHeader:
+				share.Index, err = strconv.Atoi(value)
+			case keyShareThresholdHeader:
+				share.Threshold, err = strconv.Atoi(value)
+			case keyShareRecipientHeader:
+				recipient = value
+			}
+
+			if err != nil {
+				return share, fmt.Errorf("parse %s header: %w", name, err)
+			}
+		default:
+			payload.WriteString(line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return share, err
+	}
+
+	if !begun || !ended {
+		return share, errors.New("key share armor not found")
+	}
+
+	value, err := Base64Decode([]byte(payload.String()))
+	if err != nil {
+		return share, fmt.Errorf("base64 decode key share: %w", err)
+	}
+
+	if recipient == "" {
+		share.Value = value
+
+		return share, nil
+	}
+
+	for i := range privateKeys {
+		publicKey := DerivePublicKey(privateKeys[i])
+		if KeyID(publicKey) != recipient {
+			continue
+		}
+
+		if decrypted, ok := box.OpenAnonymous(nil, value, &publicKey, &privateKeys[i]); ok {
+			share.Value = decrypted
+
+			return share, nil
+		}
+	}
+
+	return share, fmt.Errorf("%w key share %d encrypted to %q", ErrDecrypt, share.Index, recipient)
+}
+
+func splitHeader(line string) (string, string, error) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return "", "", fmt.Errorf("malformed key share header %q", line)
+	}
+
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]), nil
+}
+
+// evaluatePolynomial evaluates polynomial over GF(2^8) at x using Horner's method.
+func evaluatePolynomial(coefficients []byte, x byte) byte {
+	var result byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = gfMultiply(result, x) ^ coefficients[i]
+	}
+
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) with the AES reduction polynomial.
+func gfMultiply(a, b byte) byte {
+	var product byte
+	for i := 0; i < 8; i++ {
+		product ^= -(b & 1) & a
+		a = a<<1 ^ -(a>>7)&0x1b
+		b >>= 1
+	}
+
+	return product
+}
+
+// gfInverse returns multiplicative inverse in GF(2^8), a^254.
+func gfInverse(a byte) byte {
+	result := a
+	for i := 0; i < 6; i++ {
+		result = gfMultiply(gfMultiply(result, result), a)
+	}
+
+	return gfMultiply(result, result)
+}
@@ -0,0 +1,99 @@
+package untold
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"golang.org/x/crypto/nacl/box"
+	"testing"
+)
+
+func TestSplitCombineKey(t *testing.T) {
+	key := []byte("untold-encrypted-key:v1:c2VjcmV0IGtleQ==")
+
+	shares, err := SplitKey("production", key, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var selected []KeyShare
+		for _, i := range subset {
+			selected = append(selected, shares[i])
+		}
+
+		combined, err := CombineKey(selected)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(combined, key) {
+			t.Errorf("shares %v: expected %q, got %q", subset, key, combined)
+		}
+	}
+
+	if _, err := CombineKey(shares[:2]); !errors.Is(err, ErrNotEnoughShares) {
+		t.Errorf("expected ErrNotEnoughShares, got %v", err)
+	}
+
+	if _, err := CombineKey([]KeyShare{shares[0], shares[0], shares[1]}); err == nil {
+		t.Error("expected error for duplicate shares")
+	}
+
+	if _, err := SplitKey("production", key, 2, 3); err == nil {
+		t.Error("expected error for threshold greater than shares")
+	}
+}
+
+func TestArmorKeyShare(t *testing.T) {
+	shares, err := SplitKey("production", []byte("private key"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holderPublicKey, holderPrivateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := ArmorKeyShare(shares[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := ArmorKeyShare(shares[1], holderPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if IsEncryptedKeyShare(plain) || !IsEncryptedKeyShare(encrypted) {
+		t.Error("expected only second share to be encrypted")
+	}
+
+	if _, err := ParseKeyShare(encrypted, nil); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt, got %v", err)
+	}
+
+	first, err := ParseKeyShare(plain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := ParseKeyShare(encrypted, [][32]byte{*holderPrivateKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Environment != "production" || first.Index != 1 || second.Index != 2 || second.Threshold != 2 {
+		t.Errorf("unexpected headers %+v, %+v", first, second)
+	}
+
+	key, err := CombineKey([]KeyShare{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(key) != "private key" {
+		t.Errorf("expected %q, got %q", "private key", key)
+	}
+}
@@ -0,0 +1,62 @@
+package untold
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// NameKeyFile is the name of file in environment directory holding the key of keyed secret file names.
+// Environment without it names secret files by MD5 hash of secret's name. The name must not start with
+// dot or underscore, such files are left out by //go:embed directive naming the directory.
+const NameKeyFile = "namekey"
+
+// nameKeySize is the size of the key of keyed secret file names.
+const nameKeySize = 32
+
+// SecretFileName returns name of the file holding secret. File is named by HMAC-SHA256 of secret's name
+// under name key, or by MD5 hash of secret's name if name key is nil.
+func SecretFileName(name string, nameKey []byte) string {
+	if nameKey == nil {
+		md5Hash := md5.Sum([]byte(name))
+
+		return hex.EncodeToString(md5Hash[:])
+	}
+
+	mac := hmac.New(sha256.New, nameKey)
+	mac.Write([]byte(name))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateNameKey generates random key of keyed secret file names.
+func GenerateNameKey() ([]byte, error) {
+	nameKey := make([]byte, nameKeySize)
+	if _, err := rand.Read(nameKey); err != nil {
+		return nil, fmt.Errorf("generate name key: %w", err)
+	}
+
+	return nameKey, nil
+}
+
+// SealNameKey encrypts name key of the environment to every recipient. Result is the content of NameKeyFile.
+func SealNameKey(nameKey []byte, environment string, recipients [][32]byte) ([]byte, error) {
+	return SealSecret(nameKey, Binding{Name: NameKeyFile, Environment: environment}, recipients)
+}
+
+// OpenNameKey decrypts content of NameKeyFile of the environment.
+func OpenNameKey(content []byte, environment string, privateKeys [][32]byte) ([]byte, error) {
+	nameKey, err := OpenSecret(content, Binding{Name: NameKeyFile, Environment: environment}, privateKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nameKey) != nameKeySize {
+		return nil, fmt.Errorf("name key of %q environment is corrupted", environment)
+	}
+
+	return nameKey, nil
+}
@@ -0,0 +1,71 @@
+package untold
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestSecretFileName(t *testing.T) {
+	if name := SecretFileName("test", nil); name != "098f6bcd4621d373cade4e832627b4f6" {
+		t.Errorf("expected MD5 file name, got %q", name)
+	}
+
+	first, second := SecretFileName("test", []byte("first key")), SecretFileName("test", []byte("second key"))
+	if len(first) != 64 || first == second {
+		t.Errorf("expected file names to depend on name key, got %q and %q", first, second)
+	}
+}
+
+func TestLoadKeyedNames(t *testing.T) {
+	publicKey := testPublicKey(t)
+
+	nameKey, err := GenerateNameKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealedNameKey, err := SealNameKey(nameKey, "test", [][32]byte{publicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := SealSecret([]byte("value"), Binding{Name: "test", Environment: "test"}, [][32]byte{publicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := testFiles(t, "test/test.private")
+	files["test/test/"+NameKeyFile] = &fstest.MapFile{Data: sealedNameKey}
+	files["test/test/"+SecretFileName("test", nameKey)] = &fstest.MapFile{Data: content}
+	files["test/test/098f6bcd4621d373cade4e832627b4f6"] = &fstest.MapFile{Data: content}
+
+	v := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey())
+
+	value, err := v.Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "value" {
+		t.Errorf("expected %q, got %q", "value", value)
+	}
+
+	delete(files, "test/test/"+SecretFileName("test", nameKey))
+
+	if _, ok, err := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey()).Lookup("test"); ok || err != nil {
+		t.Errorf("expected MD5 named file to be ignored, got %v, %v", ok, err)
+	}
+}
+
+func TestLoadKeyedNamesFromEmbedFS(t *testing.T) {
+	v := NewVault(testFS, Environment("keyed"), PathPrefix("test"), AllowEmbeddedPrivateKey())
+
+	value, err := v.Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "test" {
+		t.Errorf("expected %q, got %q", "test", value)
+	}
+}
@@ -1,5 +1,7 @@
 package untold
 
+import "reflect"
+
 type Option func(v *vault)
 
 func Environment(environment string) Option {
@@ -8,14 +10,80 @@ func Environment(environment string) Option {
 	}
 }
 
+// Fallback adds environments searched, in order, for secrets missing in the main environment.
+// Each environment is decrypted with its own keys, loaded only when the search reaches the environment.
+// Private key of fallback environment is read from environment variable suffixed with its name
+// (e.g. UNTOLD_KEY_SHARED) or other key provider of the chain. Embedded key of fallback environment,
+// unless it is development, is used only with AllowEmbeddedPrivateKey option.
+func Fallback(environments ...string) Option {
+	return func(v *vault) {
+		v.fallbacks = append(v.fallbacks, environments...)
+	}
+}
+
 func EnvVariable(name string) Option {
 	return func(v *vault) {
 		v.privateKeyEnv = name
 	}
 }
 
+// KeyProviders replaces default chain of private key providers. Default chain reads, in order:
+// environment variable set by EnvVariable option, file pointed by the same variable suffixed
+// with _FILE, files given with KeyFile option, systemd credential untold-{environment},
+// Docker secret untold_{environment} and embedded {environment}.private file.
+// Providers are asked in order, until one of them provides the key.
+func KeyProviders(providers ...KeyProvider) Option {
+	return func(v *vault) {
+		v.keyProviders = append(v.keyProviders, providers...)
+	}
+}
+
+// KeyFile adds file to the default chain of private key providers. `{environment}` in the path
+// is replaced with the name of the environment. File is skipped if it does not exist.
+func KeyFile(path string) Option {
+	return func(v *vault) {
+		v.keyFiles = append(v.keyFiles, path)
+	}
+}
+
+// Passphrase sets passphrase used to decrypt private keys encrypted with passphrase.
+// Without this option passphrase is read from environment variable set by EnvVariable
+// option and suffixed with _PASSPHRASE, e.g. UNTOLD_KEY_PASSPHRASE.
+func Passphrase(passphrase string) Option {
+	return func(v *vault) {
+		v.passphrase = []byte(passphrase)
+	}
+}
+
+// DisallowEmbeddedPrivateKey fails loading secrets of any environment, which has {environment}.private
+// file embedded next to the secrets. Embedded private keys are disallowed by default for every environment
+// other than development.
+func DisallowEmbeddedPrivateKey() Option {
+	return func(v *vault) {
+		v.embeddedKeyPolicy = embeddedKeyDisallowed
+	}
+}
+
+// AllowEmbeddedPrivateKey allows private keys embedded next to the secrets in every environment.
+func AllowEmbeddedPrivateKey() Option {
+	return func(v *vault) {
+		v.embeddedKeyPolicy = embeddedKeyAllowed
+	}
+}
+
 func PathPrefix(prefix string) Option {
 	return func(v *vault) {
 		v.pathPrefix = prefix
 	}
 }
+
+// WithDecoder registers decoder used by Load for fields of type t or pointers to it.
+func WithDecoder(t reflect.Type, decoder DecoderFunc) Option {
+	return func(v *vault) {
+		if v.decoders == nil {
+			v.decoders = make(decoderRegistry)
+		}
+
+		v.decoders[t] = decoder
+	}
+}
@@ -1,53 +1,171 @@
 package untold
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 )
 
+// prefixSeparator separates name prefix, given by tag of nested struct, from names of its fields.
+const prefixSeparator = "."
+
 type resolveFn func(name string) (string, error)
 
-func parse(dst interface{}, resolve resolveFn) error {
+type parser struct {
+	resolve  resolveFn
+	decoders decoderRegistry
+	errors   []*FieldError
+	// parsing counts struct types which are being parsed up the stack
+	parsing map[reflect.Type]int
+}
+
+// parse fills dst and returns *LoadError listing every field which could not be filled.
+func parse(dst interface{}, resolve resolveFn, decoders decoderRegistry) error {
 	pointerReflection := reflect.ValueOf(dst)
 	if pointerReflection.Kind() != reflect.Ptr || pointerReflection.Elem().Kind() != reflect.Struct {
 		return fmt.Errorf("target must be a pointer to struct")
 	}
 
-	return parseRecursively(pointerReflection, resolve)
+	p := parser{resolve: resolve, decoders: decoders, parsing: make(map[reflect.Type]int)}
+	p.parseRecursively(pointerReflection, "", "")
+
+	if len(p.errors) > 0 {
+		return &LoadError{Errors: p.errors}
+	}
+
+	return nil
 }
 
-func parseRecursively(reflection reflect.Value, resolve resolveFn) error {
+// parseRecursively fills fields tagged with `untold` tag. Names of secrets are prefixed with prefix,
+// paths of fields are prefixed with path.
+func (p *parser) parseRecursively(reflection reflect.Value, prefix, path string) {
 	if reflection.Kind() == reflect.Ptr {
 		reflection = reflection.Elem()
 	}
 
+	p.parsing[reflection.Type()]++
+	defer func() { p.parsing[reflection.Type()]-- }()
+
 	for i := 0; i < reflection.Type().NumField(); i++ {
 		reflectionField := reflection.Field(i)
-		if !reflectionField.CanSet() {
+		structField := reflection.Type().Field(i)
+
+		tagValue, tagged := structField.Tag.Lookup(tagName)
+		if tagValue == "-" {
 			continue
 		}
 
+		t := parseTag(tagValue)
+
+		// exported fields of embedded struct are settable even if the struct itself is unexported
+		if !reflectionField.CanSet() && !(structField.Anonymous && reflectionField.Kind() == reflect.Struct && !p.isLeaf(reflectionField.Type(), t)) {
+			continue
+		}
+
+		fieldPath := path + structField.Name
+
 		switch {
-		case reflectionField.Kind() == reflect.Ptr && !reflectionField.Addr().IsNil() && reflectionField.CanAddr():
-			if err := parseRecursively(reflectionField, resolve); err != nil {
-				return fmt.Errorf("%q: %v", reflection.Type().Field(i).Name, err)
-			}
-		case reflectionField.Kind() == reflect.Struct:
-			if err := parseRecursively(reflectionField, resolve); err != nil {
-				return fmt.Errorf("%q: %v", reflection.Type().Field(i).Name, err)
-			}
-		case reflectionField.Kind() == reflect.String:
-			tagValue := reflection.Type().Field(i).Tag.Get("untold")
-			value, resolveErr := resolve(tagValue)
-			if resolveErr != nil {
-				return fmt.Errorf("%q: resolve %q: %v", reflection.Type().Field(i).Name, tagValue, resolveErr)
+		case tagged && p.isLeaf(reflectionField.Type(), t):
+			if err := p.parseField(reflectionField, prefix+t.name, t); err != nil {
+				p.errors = append(p.errors, &FieldError{Field: fieldPath, Secret: prefix + t.name, Err: err})
 			}
+		case reflectionField.Kind() == reflect.Ptr && isContainer(reflectionField.Type()):
+			if reflectionField.IsNil() {
+				elemType := reflectionField.Type().Elem()
+				// do not allocate structs referencing themselves, it would never end
+				if p.parsing[elemType] > 0 || !p.hasTaggedFields(elemType, make(map[reflect.Type]bool)) {
+					continue
+				}
 
-			if value != "" {
-				reflectionField.SetString(value)
+				reflectionField.Set(reflect.New(elemType))
 			}
+
+			p.parseRecursively(reflectionField, childPrefix(prefix, t), fieldPath+".")
+		case reflectionField.Kind() == reflect.Struct:
+			p.parseRecursively(reflectionField, childPrefix(prefix, t), fieldPath+".")
+		}
+	}
+}
+
+// isLeaf reports whether field of given type and tag is filled from a single secret.
+func (p *parser) isLeaf(fieldType reflect.Type, t tag) bool {
+	_, hasDecoder := p.decoders.lookup(fieldType)
+
+	return hasDecoder || t.has("json") || !isContainer(fieldType)
+}
+
+// hasTaggedFields reports whether struct type t has fields which would be filled by parser.
+func (p *parser) hasTaggedFields(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if structField.PkgPath != "" && !structField.Anonymous {
+			continue
+		}
+
+		tagValue, tagged := structField.Tag.Lookup(tagName)
+		if tagValue == "-" {
+			continue
+		}
+
+		fieldType := structField.Type
+		if tagged && p.isLeaf(fieldType, parseTag(tagValue)) {
+			return true
+		}
+
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if isContainer(fieldType) && p.hasTaggedFields(fieldType, seen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *parser) parseField(field reflect.Value, name string, t tag) error {
+	if t.name == "" {
+		return errors.New("secret name is empty")
+	}
+
+	value, err := p.resolve(name)
+	if err != nil {
+		defaultValue, hasDefault := t.get("default")
+
+		switch {
+		case errors.Is(err, ErrNotFound) && hasDefault:
+			value = defaultValue
+		case errors.Is(err, ErrNotFound) && t.has("optional"):
+			return nil
+		default:
+			return err
 		}
 	}
 
+	if value == "" {
+		return nil
+	}
+
+	if err := decodeValue(field, name, t, value, p.decoders); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
 	return nil
 }
+
+// childPrefix returns name prefix for fields of nested struct. Structs without tag name
+// or with `inline` option share the prefix of their parent.
+func childPrefix(prefix string, t tag) string {
+	if t.name == "" || t.has("inline") {
+		return prefix
+	}
+
+	return prefix + t.name + prefixSeparator
+}
@@ -1,7 +1,14 @@
 package untold
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSimpleParse(t *testing.T) {
@@ -12,7 +19,7 @@ func TestSimpleParse(t *testing.T) {
 	var h holder
 	err := parse(&h, func(name string) (string, error) {
 		return name, nil
-	})
+	}, nil)
 
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -31,7 +38,7 @@ func TestNestedParse(t *testing.T) {
 	}
 
 	var h holder
-	err := parse(&h, func(name string) (string, error) { return name, nil })
+	err := parse(&h, func(name string) (string, error) { return name, nil }, nil)
 
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -50,7 +57,7 @@ func TestNonPointerParse(t *testing.T) {
 	}
 
 	var h holder
-	err := parse(h, func(name string) (string, error) { return name, nil })
+	err := parse(h, func(name string) (string, error) { return name, nil }, nil)
 
 	if err == nil {
 		t.Errorf("expected to get a error")
@@ -68,7 +75,7 @@ func TestPointerChildParse(t *testing.T) {
 		Child: &Child{},
 	}
 
-	err := parse(&h, func(name string) (string, error) { return name, nil })
+	err := parse(&h, func(name string) (string, error) { return name, nil }, nil)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -84,7 +91,7 @@ func TestUnexportedValue(t *testing.T) {
 	}
 
 	var h holder
-	err := parse(&h, func(name string) (string, error) { return name, nil })
+	err := parse(&h, func(name string) (string, error) { return name, nil }, nil)
 
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -94,3 +101,412 @@ func TestUnexportedValue(t *testing.T) {
 		t.Errorf("expected %q, got %q", "", h.value)
 	}
 }
+
+type textValue struct {
+	value string
+}
+
+func (t *textValue) UnmarshalText(text []byte) error {
+	t.value = "text:" + string(text)
+
+	return nil
+}
+
+func TestTypedParse(t *testing.T) {
+	secrets := map[string]string{
+		"int":      "-42",
+		"uint":     "0x10",
+		"float":    "1.5",
+		"bool":     "true",
+		"duration": "1m30s",
+		"bytes":    "raw",
+		"base64":   "aGVsbG8=",
+		"hex":      "68656c6c6f",
+		"url":      "https://example.com/path",
+		"text":     "value",
+		"pointer":  "7",
+	}
+
+	type holder struct {
+		Int       int           `untold:"int"`
+		Uint      uint16        `untold:"uint"`
+		Float     float64       `untold:"float"`
+		Bool      bool          `untold:"bool"`
+		Duration  time.Duration `untold:"duration"`
+		Bytes     []byte        `untold:"bytes"`
+		Base64    []byte        `untold:"base64,encoding=base64"`
+		Hex       []byte        `untold:"hex,encoding=hex"`
+		URL       *url.URL      `untold:"url"`
+		Text      textValue     `untold:"text"`
+		Pointer   *int          `untold:"pointer"`
+		Untouched int
+	}
+
+	var h holder
+	err := parse(&h, func(name string) (string, error) { return secrets[name], nil }, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if h.Int != -42 || h.Uint != 16 || h.Float != 1.5 || !h.Bool || h.Duration != 90*time.Second {
+		t.Errorf("unexpected scalar values: %+v", h)
+	}
+
+	if string(h.Bytes) != "raw" || string(h.Base64) != "hello" || string(h.Hex) != "hello" {
+		t.Errorf("unexpected byte values: %q, %q, %q", h.Bytes, h.Base64, h.Hex)
+	}
+
+	if h.URL == nil || h.URL.Host != "example.com" {
+		t.Errorf("unexpected url: %v", h.URL)
+	}
+
+	if h.Text.value != "text:value" {
+		t.Errorf("expected %q, got %q", "text:value", h.Text.value)
+	}
+
+	if h.Pointer == nil || *h.Pointer != 7 {
+		t.Errorf("unexpected pointer value: %v", h.Pointer)
+	}
+}
+
+func TestTypedParseError(t *testing.T) {
+	type holder struct {
+		Port int `untold:"port"`
+	}
+
+	var h holder
+	err := parse(&h, func(name string) (string, error) { return "not a number", nil }, nil)
+	if err == nil {
+		t.Fatal("expected to get an error")
+	}
+
+	if !strings.Contains(err.Error(), `field "Port", secret "port": decode:`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCustomDecoderParse(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+
+	decoders := decoderRegistry{
+		reflect.TypeOf(point{}): func(name string, raw []byte) (interface{}, error) {
+			var p point
+			if _, err := fmt.Sscanf(string(raw), "%d,%d", &p.X, &p.Y); err != nil {
+				return nil, err
+			}
+
+			return &p, nil
+		},
+	}
+
+	type holder struct {
+		Value   point  `untold:"value"`
+		Pointer *point `untold:"pointer"`
+		Broken  *point `untold:"broken"`
+	}
+
+	secrets := map[string]string{"value": "1,2", "pointer": "3,4", "broken": "5,6"}
+
+	var h holder
+	err := parse(&h, func(name string) (string, error) { return secrets[name], nil }, decoders)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if h.Value != (point{X: 1, Y: 2}) {
+		t.Errorf("unexpected value: %+v", h.Value)
+	}
+
+	if h.Pointer == nil || *h.Pointer != (point{X: 3, Y: 4}) {
+		t.Errorf("unexpected pointer value: %+v", h.Pointer)
+	}
+
+	secrets["broken"] = "broken"
+	err = parse(&h, func(name string) (string, error) { return secrets[name], nil }, decoders)
+	if err == nil || !strings.Contains(err.Error(), `field "Broken", secret "broken": decode:`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestOptionalAndDefaultParse(t *testing.T) {
+	type holder struct {
+		Optional string `untold:"optional,optional"`
+		Default  string `untold:"log_level,default=info"`
+		Port     int    `untold:"port,default=8080"`
+		Existing string `untold:"existing,default=unused"`
+	}
+
+	resolve := func(name string) (string, error) {
+		if name == "existing" {
+			return "value", nil
+		}
+
+		return "", fmt.Errorf("secret %q %w", name, ErrNotFound)
+	}
+
+	h := holder{Optional: "kept"}
+	if err := parse(&h, resolve, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if h.Optional != "kept" || h.Default != "info" || h.Port != 8080 || h.Existing != "value" {
+		t.Errorf("unexpected values: %+v", h)
+	}
+}
+
+func TestOptionalDecryptErrorParse(t *testing.T) {
+	type holder struct {
+		Optional string `untold:"optional,optional"`
+	}
+
+	var h holder
+	err := parse(&h, func(name string) (string, error) {
+		return "", fmt.Errorf("secret %q: %w", name, ErrDecrypt)
+	}, nil)
+
+	if !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt, got %v", err)
+	}
+}
+
+func TestTaggedOnlyParse(t *testing.T) {
+	type database struct {
+		Password string `untold:"password"`
+	}
+
+	type holder struct {
+		Name     string
+		Skipped  string   `untold:"-"`
+		Database database `untold:"db"`
+		Inline   database `untold:"inline,inline"`
+		Embedded struct {
+			Token string `untold:"token"`
+		}
+	}
+
+	var resolved []string
+	h := holder{Name: "service"}
+	err := parse(&h, func(name string) (string, error) {
+		resolved = append(resolved, name)
+
+		return name, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if h.Name != "service" || h.Skipped != "" {
+		t.Errorf("untagged fields should be left untouched: %+v", h)
+	}
+
+	if h.Database.Password != "db.password" || h.Inline.Password != "password" || h.Embedded.Token != "token" {
+		t.Errorf("unexpected values: %+v", h)
+	}
+
+	if len(resolved) != 3 {
+		t.Errorf("expected 3 secrets to be resolved, got %v", resolved)
+	}
+}
+
+func TestCollectErrorsParse(t *testing.T) {
+	type holder struct {
+		Missing string `untold:"missing"`
+		Port    int    `untold:"port"`
+		Child   struct {
+			Missing string `untold:"missing"`
+		} `untold:"child"`
+	}
+
+	var h holder
+	err := parse(&h, func(name string) (string, error) {
+		if name == "port" {
+			return "not a number", nil
+		}
+
+		return "", fmt.Errorf("secret %q %w", name, ErrNotFound)
+	}, nil)
+
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("expected *LoadError, got %v", err)
+	}
+
+	if len(loadErr.Errors) != 3 {
+		t.Fatalf("expected 3 errors, got %v", err)
+	}
+
+	if loadErr.Errors[2].Field != "Child.Missing" || loadErr.Errors[2].Secret != "child.missing" {
+		t.Errorf("unexpected field error: %+v", loadErr.Errors[2])
+	}
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected error to match ErrNotFound")
+	}
+
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Errorf("expected error to match *strconv.NumError")
+	}
+}
+
+type embeddedSecrets struct {
+	Embedded string `untold:"embedded"`
+}
+
+type ExportedEmbeddedSecrets struct {
+	Exported string `untold:"exported"`
+}
+
+func TestAllocatePointersParse(t *testing.T) {
+	type child struct {
+		Value string `untold:"value"`
+	}
+
+	type untaggedChild struct {
+		Value string
+	}
+
+	type node struct {
+		Value string `untold:"node"`
+		Next  *node
+	}
+
+	type holder struct {
+		embeddedSecrets
+		*ExportedEmbeddedSecrets
+		Child    *child `untold:"child"`
+		Untagged *untaggedChild
+		Node     *node
+		Optional *string `untold:"optional,optional"`
+		Present  *string `untold:"present"`
+	}
+
+	var h holder
+	err := parse(&h, func(name string) (string, error) {
+		if name == "optional" {
+			return "", fmt.Errorf("secret %q %w", name, ErrNotFound)
+		}
+
+		return name, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if h.Child == nil || h.Child.Value != "child.value" {
+		t.Errorf("expected Child to be allocated and filled, got %+v", h.Child)
+	}
+
+	if h.Untagged != nil {
+		t.Errorf("expected Untagged to stay nil")
+	}
+
+	if h.Node == nil || h.Node.Value != "node" || h.Node.Next != nil {
+		t.Errorf("expected Node to be allocated once, got %+v", h.Node)
+	}
+
+	if h.Optional != nil {
+		t.Errorf("expected Optional to stay nil, got %q", *h.Optional)
+	}
+
+	if h.Present == nil || *h.Present != "present" {
+		t.Errorf("expected Present to be filled, got %v", h.Present)
+	}
+
+	if h.Embedded != "embedded" {
+		t.Errorf("expected %q, got %q", "embedded", h.Embedded)
+	}
+
+	if h.ExportedEmbeddedSecrets == nil || h.Exported != "exported" {
+		t.Errorf("expected embedded pointer to be allocated and filled")
+	}
+}
+
+func TestCollectionsParse(t *testing.T) {
+	secrets := map[string]string{
+		"keys":      `["first", "second"]`,
+		"ports":     `[80, "443"]`,
+		"timeouts":  `["1s", "2m"]`,
+		"tenants":   `{"acme": "secret", "globex": "other"}`,
+		"limits":    `{"1": 10, "2": "20"}`,
+		"not_array": `{"key": "value"}`,
+	}
+
+	type holder struct {
+		Keys     []string          `untold:"keys"`
+		Ports    []int             `untold:"ports"`
+		Timeouts []time.Duration   `untold:"timeouts"`
+		Tenants  map[string]string `untold:"tenants"`
+		Limits   map[int]uint      `untold:"limits"`
+	}
+
+	var h holder
+	err := parse(&h, func(name string) (string, error) { return secrets[name], nil }, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(h.Keys, []string{"first", "second"}) || !reflect.DeepEqual(h.Ports, []int{80, 443}) {
+		t.Errorf("unexpected slices: %v, %v", h.Keys, h.Ports)
+	}
+
+	if !reflect.DeepEqual(h.Timeouts, []time.Duration{time.Second, 2 * time.Minute}) {
+		t.Errorf("unexpected durations: %v", h.Timeouts)
+	}
+
+	if !reflect.DeepEqual(h.Tenants, map[string]string{"acme": "secret", "globex": "other"}) {
+		t.Errorf("unexpected map: %v", h.Tenants)
+	}
+
+	if !reflect.DeepEqual(h.Limits, map[int]uint{1: 10, 2: 20}) {
+		t.Errorf("unexpected typed map: %v", h.Limits)
+	}
+
+	var broken struct {
+		Keys []string `untold:"not_array"`
+	}
+
+	err = parse(&broken, func(name string) (string, error) { return secrets[name], nil }, nil)
+	if err == nil || !strings.Contains(err.Error(), "decode JSON array") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestJSONParse(t *testing.T) {
+	type credentials struct {
+		Type    string `json:"type"`
+		Project struct {
+			ID int `json:"id"`
+		} `json:"project"`
+	}
+
+	type holder struct {
+		Credentials credentials  `untold:"creds,json"`
+		Pointer     *credentials `untold:"creds,json"`
+	}
+
+	var h holder
+	err := parse(&h, func(name string) (string, error) {
+		return `{"type": "service_account", "project": {"id": 42}}`, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if h.Credentials.Type != "service_account" || h.Credentials.Project.ID != 42 {
+		t.Errorf("unexpected value: %+v", h.Credentials)
+	}
+
+	if h.Pointer == nil || h.Pointer.Project.ID != 42 {
+		t.Errorf("unexpected pointer value: %+v", h.Pointer)
+	}
+
+	err = parse(&h, func(name string) (string, error) {
+		return `{"type": "service_account", "project": {"id": "42"}}`, nil
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), `decode JSON at "project.id"`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package untold
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"io"
+)
+
+const (
+	// encryptedKeyPrefix marks private key encrypted with passphrase. It is followed by
+	// base64 encoded scrypt salt, secretbox nonce and sealed key.
+	encryptedKeyPrefix = "untold-encrypted-key:v1:"
+
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	scryptSaltLen = 16
+	nonceLen      = 24
+)
+
+var (
+	// ErrPassphrase is returned when private key can not be decrypted with given passphrase.
+	ErrPassphrase = errors.New("wrong passphrase")
+	// ErrKeyMismatch is returned when private key does not belong to the public key of the environment.
+	ErrKeyMismatch = errors.New("private key does not match public key")
+)
+
+// PassphraseFunc returns passphrase for encrypted private key. It is called only if the key is encrypted.
+type PassphraseFunc func() ([]byte, error)
+
+// DerivePublicKey computes public key belonging to the private key.
+func DerivePublicKey(privateKey [32]byte) (publicKey [32]byte) {
+	curve25519.ScalarBaseMult(&publicKey, &privateKey)
+
+	return publicKey
+}
+
+// IsEncryptedPrivateKey reports whether private key is encrypted with passphrase.
+func IsEncryptedPrivateKey(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(content), []byte(encryptedKeyPrefix))
+}
+
+// EncryptPrivateKey encrypts private key with a key derived from passphrase by scrypt.
+func EncryptPrivateKey(privateKey [32]byte, passphrase []byte) ([]byte, error) {
+	var salt [scryptSaltLen]byte
+	if _, err := io.ReadFull(rand.Reader, salt[:]); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+
+	var nonce [nonceLen]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	key, err := passphraseKey(passphrase, salt[:])
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := append(salt[:], nonce[:]...)
+	sealed = secretbox.Seal(sealed, privateKey[:], &nonce, &key)
+
+	return append([]byte(encryptedKeyPrefix), Base64Encode(sealed)...), nil
+}
+
+// DecodePrivateKeys decodes comma separated list of private keys, e.g. UNTOLD_KEY=key1,key2.
+func DecodePrivateKeys(content []byte, passphrase PassphraseFunc) ([][32]byte, error) {
+	parts := bytes.Split(bytes.TrimSpace(content), []byte(","))
+
+	var privateKeys [][32]byte
+	for i := range parts {
+		privateKey, err := DecodePrivateKey(parts[i], passphrase)
+		if err != nil && len(parts) > 1 {
+			return nil, fmt.Errorf("key %d: %w", i+1, err)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		privateKeys = append(privateKeys, privateKey)
+	}
+
+	return privateKeys, nil
+}
+
+// DecodePrivateKey decodes base64 encoded private key, decrypting it first if it is encrypted with passphrase.
+func DecodePrivateKey(content []byte, passphrase PassphraseFunc) (privateKey [32]byte, err error) {
+	content = bytes.TrimSpace(content)
+	if !IsEncryptedPrivateKey(content) {
+		return DecodeBase64Key(content)
+	}
+
+	sealed, err := Base64Decode(content[len(encryptedKeyPrefix):])
+	if err != nil {
+		return privateKey, fmt.Errorf("decode encrypted key: %w", err)
+	}
+
+	if len(sealed) != scryptSaltLen+nonceLen+secretbox.Overhead+len(privateKey) {
+		return privateKey, errors.New("corrupted key")
+	}
+
+	if passphrase == nil {
+		return privateKey, errors.New("key is encrypted, passphrase is required")
+	}
+
+	phrase, err := passphrase()
+	if err != nil {
+		return privateKey, fmt.Errorf("get passphrase: %w", err)
+	}
+
+	key, err := passphraseKey(phrase, sealed[:scryptSaltLen])
+	if err != nil {
+		return privateKey, err
+	}
+
+	var nonce [nonceLen]byte
+	copy(nonce[:], sealed[scryptSaltLen:scryptSaltLen+nonceLen])
+
+	opened, ok := secretbox.Open(nil, sealed[scryptSaltLen+nonceLen:], &nonce, &key)
+	if !ok {
+		return privateKey, ErrPassphrase
+	}
+
+	copy(privateKey[:], opened)
+
+	return privateKey, nil
+}
+
+func passphraseKey(passphrase, salt []byte) (key [32]byte, err error) {
+	derived, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, len(key))
+	if err != nil {
+		return key, fmt.Errorf("derive key from passphrase: %w", err)
+	}
+
+	copy(key[:], derived)
+
+	return key, nil
+}
@@ -0,0 +1,85 @@
+package untold
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEncryptPrivateKey(t *testing.T) {
+	base64PrivateKey, err := testFS.ReadFile("test/test.private")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateKey, err := DecodeBase64Key(base64PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := EncryptPrivateKey(privateKey, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsEncryptedPrivateKey(encrypted) || IsEncryptedPrivateKey(base64PrivateKey) {
+		t.Errorf("IsEncryptedPrivateKey reports wrong result")
+	}
+
+	decrypted, err := DecodePrivateKey(encrypted, func() ([]byte, error) { return []byte("passphrase"), nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decrypted != privateKey {
+		t.Errorf("decrypted key does not match original")
+	}
+
+	if _, err := DecodePrivateKey(encrypted, func() ([]byte, error) { return []byte("wrong"), nil }); !errors.Is(err, ErrPassphrase) {
+		t.Errorf("expected ErrPassphrase, got %v", err)
+	}
+
+	if _, err := DecodePrivateKey(encrypted, nil); err == nil {
+		t.Errorf("expected error for missing passphrase")
+	}
+}
+
+func TestLoadEncryptedPrivateKey(t *testing.T) {
+	base64PrivateKey, err := testFS.ReadFile("test/test.private")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateKey, err := DecodeBase64Key(base64PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := EncryptPrivateKey(privateKey, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := fstest.MapFS{"test/test.private": &fstest.MapFile{Data: encrypted}}
+	for _, name := range []string{"test/test.public", "test/test/098f6bcd4621d373cade4e832627b4f6"} {
+		content, err := testFS.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[name] = &fstest.MapFile{Data: content}
+	}
+
+	value, err := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), Passphrase("passphrase")).Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "test" {
+		t.Errorf("expected %q, got %q", "test", value)
+	}
+
+	if _, err := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), Passphrase("wrong")).Get("test"); !errors.Is(err, ErrPassphrase) {
+		t.Errorf("expected ErrPassphrase, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package untold
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Recipient is a holder of a key secrets are encrypted to, in addition to the environment key.
+// Recipients of environment are listed in {environment}.recipients file, one per line,
+// as base64 encoded public key followed by recipient's name.
+type Recipient struct {
+	Name      string
+	PublicKey [32]byte
+}
+
+// ParseRecipients parses content of recipients file. Empty lines and lines starting with # are ignored.
+func ParseRecipients(content []byte) ([]Recipient, error) {
+	var recipients []Recipient
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected public key and name", line)
+		}
+
+		publicKey, err := DecodeBase64Key([]byte(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: decode public key: %w", line, err)
+		}
+
+		recipients = append(recipients, Recipient{Name: fields[1], PublicKey: publicKey})
+	}
+
+	return recipients, scanner.Err()
+}
+
+// FormatRecipients formats recipients as content of recipients file.
+func FormatRecipients(recipients []Recipient) []byte {
+	var buffer bytes.Buffer
+	for i := range recipients {
+		fmt.Fprintf(&buffer, "%s %s\n", Base64Encode(recipients[i].PublicKey[:]), recipients[i].Name)
+	}
+
+	return buffer.Bytes()
+}
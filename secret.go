@@ -0,0 +1,159 @@
+package untold
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// KeyID returns short fingerprint of public key, recorded with every ciphertext encrypted to it.
+func KeyID(publicKey [32]byte) string {
+	hash := sha256.Sum256(publicKey[:])
+
+	return hex.EncodeToString(hash[:4])
+}
+
+// ErrTampered is returned when secret is bound to other name or environment than it was read as,
+// e.g. when secret file was copied over another one.
+var ErrTampered = errors.New("secret was tampered with")
+
+// boundPayloadMagic starts encrypted payload of bound secret. Envelope version in the header is not authenticated,
+// so the magic lets bound payload be recognized inside envelope downgraded to unbound version.
+const boundPayloadMagic = "\x00untold-bound\x00"
+
+// Binding ties secret value to the name and environment it is stored under. Binding is authenticated
+// together with the value, so secret file copied to other name or environment fails to open.
+// Zero Binding leaves secret unbound.
+type Binding struct {
+	Name, Environment string
+}
+
+// SealSecret encrypts value bound to its name and environment to every recipient. Result is the content
+// of secret file: envelope holding ciphertext for each recipient, tagged with ID of recipient's key.
+func SealSecret(value []byte, binding Binding, recipients [][32]byte) ([]byte, error) {
+	envelope := Envelope{Version: EnvelopeVersion, Algorithm: AlgorithmSealedBox}
+
+	payload := value
+	switch {
+	case binding == (Binding{}):
+		envelope.Version = unboundEnvelopeVersion
+	case len(binding.Name) > 0xffff || len(binding.Environment) > 0xffff:
+		return nil, errors.New("secret name or environment is too long")
+	default:
+		payload = bindPayload(value, binding)
+		defer wipe(payload)
+	}
+
+	for i := range recipients {
+		encrypted, err := box.SealAnonymous(nil, payload, &recipients[i], rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt secret: %w", err)
+		}
+
+		envelope.Ciphertexts = append(envelope.Ciphertexts, Ciphertext{KeyID: KeyID(recipients[i]), Data: encrypted})
+	}
+
+	return envelope.Bytes(), nil
+}
+
+// OpenSecret decrypts content of secret file and checks it is bound to given name and environment.
+// ErrTampered is returned if secret is bound to other name or environment, or if envelope version was
+// changed to hide or fake the binding. Secrets written before binding was introduced are not checked.
+func OpenSecret(content []byte, binding Binding, privateKeys [][32]byte) ([]byte, error) {
+	value, recorded, err := OpenSecretBinding(content, privateKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if recorded != (Binding{}) && recorded != binding {
+		wipe(value)
+
+		return nil, fmt.Errorf("%w: secret %q of %q environment holds secret %q of %q environment",
+			ErrTampered, binding.Name, binding.Environment, recorded.Name, recorded.Environment)
+	}
+
+	return value, nil
+}
+
+// OpenSecretBinding decrypts content of secret file with the first private key able to open one of its
+// ciphertexts and returns the value together with its binding, which is zero for unbound secrets.
+// Ciphertexts are opened only with keys matching recorded key ID, ciphertexts without key ID are tried
+// with every key. ErrDecrypt is returned if none of the keys fits.
+func OpenSecretBinding(content []byte, privateKeys [][32]byte) ([]byte, Binding, error) {
+	envelope, err := ParseEnvelope(content)
+	if err != nil {
+		return nil, Binding{}, err
+	}
+
+	publicKeys := make([][32]byte, len(privateKeys))
+	keyIDs := make([]string, len(privateKeys))
+	for i := range privateKeys {
+		publicKeys[i] = DerivePublicKey(privateKeys[i])
+		keyIDs[i] = KeyID(publicKeys[i])
+	}
+
+	for _, ciphertext := range envelope.Ciphertexts {
+		for i := range privateKeys {
+			if ciphertext.KeyID != "" && ciphertext.KeyID != keyIDs[i] {
+				continue
+			}
+
+			decrypted, ok := box.OpenAnonymous(nil, ciphertext.Data, &publicKeys[i], &privateKeys[i])
+			if !ok {
+				continue
+			}
+
+			if !envelope.Bound() {
+				if bytes.HasPrefix(decrypted, []byte(boundPayloadMagic)) {
+					wipe(decrypted)
+
+					return nil, Binding{}, fmt.Errorf("%w: bound secret in envelope of version %d", ErrTampered, envelope.Version)
+				}
+
+				return decrypted, Binding{}, nil
+			}
+
+			return unbindPayload(decrypted)
+		}
+	}
+
+	return nil, Binding{}, ErrDecrypt
+}
+
+// bindPayload prefixes value with magic and length prefixed name and environment.
+func bindPayload(value []byte, binding Binding) []byte {
+	payload := make([]byte, 0, len(boundPayloadMagic)+4+len(binding.Name)+len(binding.Environment)+len(value))
+	payload = append(payload, boundPayloadMagic...)
+	for _, field := range []string{binding.Name, binding.Environment} {
+		payload = append(payload, byte(len(field)>>8), byte(len(field)))
+		payload = append(payload, field...)
+	}
+
+	return append(payload, value...)
+}
+
+// unbindPayload splits payload into binding and value.
+func unbindPayload(payload []byte) ([]byte, Binding, error) {
+	if !bytes.HasPrefix(payload, []byte(boundPayloadMagic)) {
+		wipe(payload)
+
+		return nil, Binding{}, fmt.Errorf("%w: unbound secret in envelope of bound version", ErrTampered)
+	}
+
+	var fields [2]string
+	rest := payload[len(boundPayloadMagic):]
+	for i := range fields {
+		if len(rest) < 2 || len(rest)-2 < int(rest[0])<<8|int(rest[1]) {
+			return nil, Binding{}, errors.New("malformed secret binding")
+		}
+
+		length := int(rest[0])<<8 | int(rest[1])
+		fields[i], rest = string(rest[2:2+length]), rest[2+length:]
+	}
+
+	return rest, Binding{Name: fields[0], Environment: fields[1]}, nil
+}
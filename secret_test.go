@@ -0,0 +1,211 @@
+package untold
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/nacl/box"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSealSecretToRecipients(t *testing.T) {
+	firstPublic, firstPrivate, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secondPublic, secondPrivate, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, otherPrivate, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := SealSecret([]byte("value"), Binding{Name: "value", Environment: "test"}, [][32]byte{*firstPublic, *secondPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, privateKey := range []*[32]byte{firstPrivate, secondPrivate} {
+		value, err := OpenSecret(content, Binding{Name: "value", Environment: "test"}, [][32]byte{*otherPrivate, *privateKey})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(value) != "value" {
+			t.Errorf("expected %q, got %q", "value", value)
+		}
+	}
+
+	if _, err := OpenSecret(content, Binding{Name: "value", Environment: "test"}, [][32]byte{*otherPrivate}); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt, got %v", err)
+	}
+}
+
+func TestLoadWithRecipientKey(t *testing.T) {
+	publicKey := testPublicKey(t)
+
+	recipientPublic, recipientPrivate, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := SealSecret([]byte("value"), Binding{Name: "test", Environment: "test"}, [][32]byte{publicKey, *recipientPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := testFiles(t, "test/test.public")
+	files["test/test.recipients"] = &fstest.MapFile{Data: FormatRecipients([]Recipient{{Name: "alice", PublicKey: *recipientPublic}})}
+	files["test/test/098f6bcd4621d373cade4e832627b4f6"] = &fstest.MapFile{Data: content}
+
+	recipientKey := KeyProviderFunc(func(fs.FS, string) ([]byte, error) {
+		return Base64Encode(recipientPrivate[:]), nil
+	})
+
+	value, err := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), KeyProviders(recipientKey)).Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "value" {
+		t.Errorf("expected %q, got %q", "value", value)
+	}
+
+	delete(files, "test/test.recipients")
+
+	_, err = NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), KeyProviders(recipientKey)).Get("test")
+	if !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("expected ErrKeyMismatch for removed recipient, got %v", err)
+	}
+}
+
+func TestLoadWithSeveralKeys(t *testing.T) {
+	publicKey := testPublicKey(t)
+	files := testFiles(t, "test/test.public", "test/test.private")
+	environmentPrivate := files["test/test.private"].Data
+
+	oldPublic, oldPrivate, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// secret encrypted only to the old key, which is being retired
+	content, err := SealSecret([]byte("value"), Binding{Name: "test", Environment: "test"}, [][32]byte{*oldPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envelope, err := ParseEnvelope(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if envelope.Ciphertexts[0].KeyID != KeyID(*oldPublic) {
+		t.Errorf("expected ciphertext to be tagged with key ID, got %q", content)
+	}
+
+	files["test/test.recipients"] = &fstest.MapFile{Data: FormatRecipients([]Recipient{{Name: "retiring", PublicKey: *oldPublic}})}
+	files["test/test/098f6bcd4621d373cade4e832627b4f6"] = &fstest.MapFile{Data: content}
+
+	keys := KeyProviderFunc(func(fs.FS, string) ([]byte, error) {
+		return []byte(string(environmentPrivate) + "," + string(Base64Encode(oldPrivate[:]))), nil
+	})
+
+	value, err := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), KeyProviders(keys)).Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "value" {
+		t.Errorf("expected %q, got %q", "value", value)
+	}
+
+	if KeyID(publicKey) == KeyID(*oldPublic) {
+		t.Errorf("expected different keys to have different IDs")
+	}
+}
+
+func TestSecretBinding(t *testing.T) {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	binding := Binding{Name: "api_key", Environment: "production"}
+
+	content, err := SealSecret([]byte("value"), binding, [][32]byte{*publicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, recorded, err := OpenSecretBinding(content, [][32]byte{*privateKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(value) != "value" || recorded != binding {
+		t.Errorf("expected %q bound to %+v, got %q bound to %+v", "value", binding, value, recorded)
+	}
+
+	for _, other := range []Binding{{Name: "db_password", Environment: "production"}, {Name: "api_key", Environment: "development"}} {
+		if _, err := OpenSecret(content, other, [][32]byte{*privateKey}); !errors.Is(err, ErrTampered) {
+			t.Errorf("expected ErrTampered for %+v, got %v", other, err)
+		}
+	}
+
+	unbound, err := SealSecret([]byte("value"), Binding{}, [][32]byte{*publicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := OpenSecret(unbound, binding, [][32]byte{*privateKey}); err != nil || string(value) != "value" {
+		t.Errorf("expected unbound secret to open, got %q, %v", value, err)
+	}
+}
+
+func TestLoadTamperedSecret(t *testing.T) {
+	publicKey := testPublicKey(t)
+
+	// value of "other" secret copied over the file of "test" secret
+	content, err := SealSecret([]byte("value"), Binding{Name: "other", Environment: "test"}, [][32]byte{publicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := testFiles(t, "test/test.private")
+	files["test/test/098f6bcd4621d373cade4e832627b4f6"] = &fstest.MapFile{Data: content}
+
+	if _, err := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey()).Get("test"); !errors.Is(err, ErrTampered) {
+		t.Errorf("expected ErrTampered, got %v", err)
+	}
+
+	// copied file with envelope header downgraded to unbound version, or removed
+	header := fmt.Sprintf("%s v%d %s\n", envelopeMagic, EnvelopeVersion, AlgorithmSealedBox)
+	for _, downgraded := range []string{fmt.Sprintf("%s v%d %s\n", envelopeMagic, unboundEnvelopeVersion, AlgorithmSealedBox), ""} {
+		files["test/test/098f6bcd4621d373cade4e832627b4f6"] = &fstest.MapFile{Data: bytes.Replace(content, []byte(header), []byte(downgraded), 1)}
+
+		if _, err := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey()).Get("test"); !errors.Is(err, ErrTampered) {
+			t.Errorf("expected ErrTampered for header %q, got %v", downgraded, err)
+		}
+	}
+
+	// unbound secret with envelope header upgraded to bound version
+	unbound, err := SealSecret([]byte("value"), Binding{}, [][32]byte{publicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upgraded := bytes.Replace(unbound, []byte(fmt.Sprintf(" v%d ", unboundEnvelopeVersion)), []byte(fmt.Sprintf(" v%d ", EnvelopeVersion)), 1)
+	files["test/test/098f6bcd4621d373cade4e832627b4f6"] = &fstest.MapFile{Data: upgraded}
+
+	if _, err := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey()).Get("test"); !errors.Is(err, ErrTampered) {
+		t.Errorf("expected ErrTampered for upgraded header, got %v", err)
+	}
+}
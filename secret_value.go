@@ -0,0 +1,62 @@
+package untold
+
+import (
+	"fmt"
+)
+
+const redacted = "[REDACTED]"
+
+// Secret holds secret value, which is redacted when printed, logged or marshalled.
+// Load fills fields of Secret type like any other field.
+type Secret struct {
+	value []byte
+}
+
+// NewSecret creates Secret holding a copy of value.
+func NewSecret(value []byte) Secret {
+	return Secret{value: append([]byte(nil), value...)}
+}
+
+// Reveal returns secret value.
+func (s Secret) Reveal() string { return string(s.value) }
+
+// RevealBytes returns secret value without copying it. Returned bytes are zeroed by Destroy.
+func (s Secret) RevealBytes() []byte { return s.value }
+
+// Destroy zeroes bytes backing secret value.
+func (s *Secret) Destroy() {
+	wipe(s.value)
+	s.value = nil
+}
+
+func (s Secret) String() string { return redacted }
+
+func (s Secret) GoString() string { return redacted }
+
+func (s Secret) Format(f fmt.State, verb rune) { _, _ = f.Write([]byte(redacted)) }
+
+func (s Secret) MarshalJSON() ([]byte, error) { return []byte(`"` + redacted + `"`), nil }
+
+func (s Secret) MarshalText() ([]byte, error) { return []byte(redacted), nil }
+
+// UnmarshalText sets secret value to a copy of text.
+func (s *Secret) UnmarshalText(text []byte) error {
+	s.Destroy()
+	s.value = append([]byte(nil), text...)
+
+	return nil
+}
+
+// wipe zeroes bytes.
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// wipeKeys zeroes keys.
+func wipeKeys(keys [][32]byte) {
+	for i := range keys {
+		wipe(keys[i][:])
+	}
+}
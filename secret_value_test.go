@@ -0,0 +1,93 @@
+package untold
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSecretRedacted(t *testing.T) {
+	config := struct {
+		Password Secret `json:"password"`
+	}{Password: NewSecret([]byte("hunter2"))}
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x"} {
+		if output := fmt.Sprintf(format, config); !strings.Contains(output, redacted) || strings.Contains(output, "hunter2") {
+			t.Errorf("%s revealed secret: %s", format, output)
+		}
+	}
+
+	marshalled, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(marshalled) != `{"password":"[REDACTED]"}` {
+		t.Errorf("JSON revealed secret: %s", marshalled)
+	}
+
+	if config.Password.Reveal() != "hunter2" {
+		t.Errorf("expected %q, got %q", "hunter2", config.Password.Reveal())
+	}
+}
+
+func TestSecretDestroy(t *testing.T) {
+	secret := NewSecret([]byte("password"))
+	value := secret.RevealBytes()
+
+	secret.Destroy()
+
+	if string(value) != string(make([]byte, len("password"))) {
+		t.Errorf("expected bytes to be zeroed, got %q", value)
+	}
+
+	if secret.Reveal() != "" {
+		t.Errorf("expected empty value, got %q", secret.Reveal())
+	}
+}
+
+func TestLoadSecret(t *testing.T) {
+	v := NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey())
+
+	var config struct {
+		Test Secret `untold:"test"`
+	}
+
+	if err := v.Load(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Test.Reveal() != "test" {
+		t.Errorf("expected %q, got %q", "test", config.Test.Reveal())
+	}
+}
+
+func TestClose(t *testing.T) {
+	v := NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), Passphrase("passphrase"))
+
+	if _, err := v.Get("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, passphrase := v.(*vault).states["test"].keys, v.(*vault).passphrase
+
+	if err := v.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range keys {
+		if keys[i] != [32]byte{} {
+			t.Error("expected keys to be wiped")
+		}
+	}
+
+	if string(passphrase) != string(make([]byte, len(passphrase))) {
+		t.Errorf("expected passphrase to be wiped, got %q", passphrase)
+	}
+
+	if _, err := v.Get("test"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
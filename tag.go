@@ -0,0 +1,43 @@
+package untold
+
+import "strings"
+
+const tagName = "untold"
+
+// tag holds parsed `untold` struct tag, e.g. `untold:"signing_key,encoding=base64"`.
+type tag struct {
+	name    string
+	options map[string]string
+}
+
+func parseTag(value string) tag {
+	parts := strings.Split(value, ",")
+
+	result := tag{
+		name:    parts[0],
+		options: make(map[string]string, len(parts)-1),
+	}
+
+	for _, option := range parts[1:] {
+		key, value := option, ""
+		if i := strings.Index(option, "="); i >= 0 {
+			key, value = option[:i], option[i+1:]
+		}
+
+		result.options[strings.TrimSpace(key)] = value
+	}
+
+	return result
+}
+
+func (t tag) has(option string) bool {
+	_, ok := t.options[option]
+
+	return ok
+}
+
+func (t tag) get(option string) (string, bool) {
+	value, ok := t.options[option]
+
+	return value, ok
+}
@@ -1,13 +1,16 @@
 package untold
 
 import (
-	"crypto/md5"
-	"embed"
-	"encoding/hex"
+	"bytes"
+	"errors"
 	"fmt"
-	"golang.org/x/crypto/nacl/box"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 const (
@@ -16,34 +19,79 @@ const (
 	DefaultEnvironmentVariable = "UNTOLD_KEY"
 )
 
-var (
-	zeroKey [32]byte
-)
+var nonAlphanumeric = regexp.MustCompile("[^a-zA-Z0-9]+")
 
 type Vault interface {
-	Load(interface{}) error
+	// Load fills fields of struct pointed by dst with secrets named by `untold` tags.
+	Load(dst interface{}) error
+	// Get returns value of the secret. ErrNotFound is returned if secret does not exist.
+	Get(name string) (string, error)
+	// GetBytes returns value of the secret as bytes. ErrNotFound is returned if secret does not exist.
+	GetBytes(name string) ([]byte, error)
+	// Lookup returns value of the secret and reports whether it exists.
+	Lookup(name string) (string, bool, error)
+	// Names returns sorted names of secrets listed in encrypted indexes of the environment and its fallbacks.
+	Names() ([]string, error)
+	// Close wipes decrypted keys from memory. Vault can not be used after it is closed.
+	Close() error
 }
 
 type vault struct {
-	embeddedFiles                          embed.FS
+	files                                  fs.FS
 	pathPrefix, environment, privateKeyEnv string
-	publicKey, privateKey                  [32]byte
+	fallbacks                              []string
+	keyProviders                           []KeyProvider
+	keyFiles                               []string
+	passphrase                             []byte
+	decoders                               decoderRegistry
+	embeddedKeyPolicy                      embeddedKeyPolicy
+	// states holds keys and files of the main environment and its fallbacks, keyed by environment name.
+	states map[string]*environmentState
+	// mu guards states against Close and closed, so getters of the vault can be used concurrently.
+	mu     sync.RWMutex
+	closed bool
+}
+
+// environmentState holds keys and files of the environment. Environment is loaded when secret lookup
+// first reaches it, so keys of fallback environments are not needed while main environment has the secret.
+type environmentState struct {
+	mu          sync.Mutex
+	environment string
+	loaded      bool
+	keys        [][32]byte
+	nameKey     []byte
+	bundle      map[string][]byte
 }
 
-func NewVault(files embed.FS, options ...Option) Vault {
+// embeddedKeyPolicy controls whether private keys may be embedded next to the secrets.
+type embeddedKeyPolicy int
+
+const (
+	// embeddedKeyDefault allows embedded private key in development environment only.
+	embeddedKeyDefault embeddedKeyPolicy = iota
+	embeddedKeyAllowed
+	embeddedKeyDisallowed
+)
+
+// NewVault creates Vault reading keys and secrets from files. Any fs.FS
+// can be used: embed.FS, os.DirFS, fstest.MapFS, a result of fs.Sub or zip.Reader.
+func NewVault(files fs.FS, options ...Option) Vault {
 	v := vault{
-		embeddedFiles: files,
+		files:         files,
 		pathPrefix:    DefaultPathPrefix,
 		environment:   DefaultEnvironment,
 		privateKeyEnv: DefaultEnvironmentVariable,
-		privateKey:    zeroKey,
-		publicKey:     zeroKey,
 	}
 
 	for i := range options {
 		options[i](&v)
 	}
 
+	v.states = make(map[string]*environmentState)
+	for _, environment := range v.environments() {
+		v.states[environment] = &environmentState{environment: environment}
+	}
+
 	return &v
 }
 
@@ -52,61 +100,493 @@ func (v *vault) Load(dst interface{}) error {
 		return err
 	}
 
-	return parse(dst, v.findSecret)
+	// environments records, by secret name, environment the secret was found or failed in
+	environments := make(map[string]string)
+	err := parse(dst, func(name string) (string, error) {
+		value, environment, err := v.getSecret(name)
+		defer wipe(value)
+
+		environments[name] = environment
+
+		return string(value), err
+	}, v.decoders)
+
+	var loadErr *LoadError
+	if errors.As(err, &loadErr) {
+		for i := range loadErr.Errors {
+			loadErr.Errors[i].Environment = environments[loadErr.Errors[i].Secret]
+		}
+	}
+
+	return err
 }
 
-func (v *vault) loadKeys() error {
-	if v.privateKey != zeroKey || v.publicKey != zeroKey {
-		return nil
+func (v *vault) Get(name string) (string, error) {
+	value, err := v.GetBytes(name)
+	if err != nil {
+		return "", err
 	}
 
-	base64PublicKey, err := v.embeddedFiles.ReadFile(filepath.Join(v.pathPrefix, v.environment+".public"))
+	return string(value), nil
+}
+
+func (v *vault) GetBytes(name string) ([]byte, error) {
+	value, _, err := v.getSecret(name)
+
+	return value, err
+}
+
+// getSecret loads keys and finds secret, see findSecret.
+func (v *vault) getSecret(name string) ([]byte, string, error) {
+	if err := v.readLock(); err != nil {
+		return nil, "", err
+	}
+	defer v.mu.RUnlock()
+
+	return v.findSecret(name)
+}
+
+func (v *vault) Lookup(name string) (string, bool, error) {
+	value, err := v.Get(name)
 	if err != nil {
-		return fmt.Errorf("read public key file for %q environment: %s", v.environment, err)
+		if errors.Is(err, ErrNotFound) {
+			return "", false, nil
+		}
+
+		return "", false, err
+	}
+
+	return value, true, nil
+}
+
+// environments returns environments secrets are searched in, in order.
+func (v *vault) environments() []string {
+	return append([]string{v.environment}, v.fallbacks...)
+}
+
+func (v *vault) Names() ([]string, error) {
+	if err := v.readLock(); err != nil {
+		return nil, err
 	}
+	defer v.mu.RUnlock()
 
-	base64PrivateKey := []byte(os.Getenv(v.privateKeyEnv))
-	if len(base64PrivateKey) == 0 {
-		base64PrivateKey, err = v.embeddedFiles.ReadFile(filepath.Join(v.pathPrefix, v.environment+".private"))
+	var names []string
+	for _, environment := range v.environments() {
+		state, err := v.loadEnvironment(environment)
 		if err != nil {
-			return fmt.Errorf("read private key file for %q environment: %s", v.environment, err)
+			return nil, err
+		}
+
+		content, err := v.readEnvironmentFile(state, IndexFile)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
 		}
+
+		if err != nil {
+			return nil, fmt.Errorf("read index of %q environment: %w", environment, err)
+		}
+
+		environmentNames, err := OpenIndex(content, environment, state.keys)
+		if err != nil {
+			return nil, fmt.Errorf("open index of %q environment: %w", environment, err)
+		}
+
+		names = append(names, environmentNames...)
+	}
+
+	return SortNames(names), nil
+}
+
+func (v *vault) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, state := range v.states {
+		wipeKeys(state.keys)
+		wipe(state.nameKey)
+		state.keys, state.nameKey, state.bundle, state.loaded = nil, nil, nil, false
+	}
+
+	wipe(v.passphrase)
+	v.passphrase = nil
+	v.closed = true
+
+	return nil
+}
+
+// readLock locks the vault for reading and loads main environment, so its errors are reported before
+// any secret is looked up. Caller unlocks v.mu.
+func (v *vault) readLock() error {
+	v.mu.RLock()
+	if v.closed {
+		v.mu.RUnlock()
+
+		return ErrClosed
+	}
+
+	if _, err := v.loadEnvironment(v.environment); err != nil {
+		v.mu.RUnlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// loadKeys loads keys of main environment, unless they are loaded already.
+func (v *vault) loadKeys() error {
+	if err := v.readLock(); err != nil {
+		return err
 	}
 
-	v.publicKey, err = DecodeBase64Key(base64PublicKey)
+	v.mu.RUnlock()
+
+	return nil
+}
+
+// loadEnvironment loads keys, bundle and name key of the environment, unless they are loaded already.
+// Caller holds read lock of the vault.
+func (v *vault) loadEnvironment(environment string) (*environmentState, error) {
+	state := v.states[environment]
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.loaded {
+		return state, nil
+	}
+
+	privateKeys, err := v.loadEnvironmentKeys(environment)
 	if err != nil {
-		return fmt.Errorf("decode base64 encoded public key: %s", err)
+		return nil, err
 	}
 
-	v.privateKey, err = DecodeBase64Key(base64PrivateKey)
+	bundle, err := v.loadBundle(environment)
 	if err != nil {
-		return fmt.Errorf("decode base64 encoded private key: %s", err)
+		wipeKeys(privateKeys)
+
+		return nil, err
 	}
 
-	return nil
+	state.keys, state.bundle = privateKeys, bundle
+
+	state.nameKey, err = v.loadNameKey(state)
+	if err != nil {
+		wipeKeys(privateKeys)
+		state.keys, state.bundle = nil, nil
+
+		return nil, err
+	}
+
+	state.loaded = true
+
+	return state, nil
+}
+
+// loadBundle loads files of the environment stored in bundle file, nil if environment is stored in directory.
+func (v *vault) loadBundle(environment string) (map[string][]byte, error) {
+	content, err := fs.ReadFile(v.files, path.Join(v.pathPrefix, environment+BundleExtension))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read bundle of %q environment: %w", environment, err)
+	}
+
+	files, err := ParseBundle(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse bundle of %q environment: %w", environment, err)
+	}
+
+	return files, nil
+}
+
+// readEnvironmentFile reads file of the environment from its bundle, if environment has one, or from its directory.
+func (v *vault) readEnvironmentFile(state *environmentState, name string) ([]byte, error) {
+	if state.bundle != nil {
+		content, ok := state.bundle[name]
+		if !ok {
+			return nil, fmt.Errorf("%s in bundle of %q environment: %w", name, state.environment, fs.ErrNotExist)
+		}
+
+		return content, nil
+	}
+
+	return fs.ReadFile(v.files, path.Join(v.pathPrefix, state.environment, name))
+}
+
+// loadNameKey loads key of keyed secret file names of the environment, nil if secret files are named by MD5 hash.
+func (v *vault) loadNameKey(state *environmentState) ([]byte, error) {
+	environment := state.environment
+
+	content, err := v.readEnvironmentFile(state, NameKeyFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read name key for %q environment: %w", environment, err)
+	}
+
+	nameKey, err := OpenNameKey(content, environment, state.keys)
+	if err != nil {
+		return nil, fmt.Errorf("open name key for %q environment: %w", environment, err)
+	}
+
+	return nameKey, nil
+}
+
+// loadEnvironmentKeys loads private keys of the environment. Several comma separated keys can be provided,
+// e.g. while old key is being retired. Public key file is optional, but if it exists only keys matching it
+// or belonging to environment's recipients are used, and at least one of them must match.
+func (v *vault) loadEnvironmentKeys(environment string) ([][32]byte, error) {
+	if err := v.checkEmbeddedKey(environment); err != nil {
+		return nil, err
+	}
+
+	base64PrivateKeys, err := v.providePrivateKey(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeys, err := DecodePrivateKeys(base64PrivateKeys, v.passphraseFor(environment))
+	wipe(base64PrivateKeys)
+	if err != nil {
+		return nil, fmt.Errorf("decode private key for %q environment: %w", environment, err)
+	}
+
+	publicKeys, err := v.loadPublicKeys(environment)
+	if err != nil {
+		wipeKeys(privateKeys)
+
+		return nil, err
+	}
+
+	if publicKeys == nil {
+		return privateKeys, nil
+	}
+
+	var matching [][32]byte
+	for i := range privateKeys {
+		derived := DerivePublicKey(privateKeys[i])
+		for j := range publicKeys {
+			if derived == publicKeys[j] {
+				matching = append(matching, privateKeys[i])
+
+				break
+			}
+		}
+	}
+
+	// matching keys are copied, all decoded keys are wiped
+	wipeKeys(privateKeys)
+
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("%w for environment %q", ErrKeyMismatch, environment)
+	}
+
+	return matching, nil
+}
+
+// loadPublicKeys loads public key of the environment followed by public keys of its recipients.
+// Nil is returned if {environment}.public file does not exist.
+func (v *vault) loadPublicKeys(environment string) ([][32]byte, error) {
+	base64PublicKey, err := fs.ReadFile(v.files, path.Join(v.pathPrefix, environment+".public"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("read public key file for %q environment: %s", environment, err)
+	}
+
+	publicKey, err := DecodeBase64Key(base64PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode base64 encoded public key: %s", err)
+	}
+
+	publicKeys := [][32]byte{publicKey}
+
+	content, err := fs.ReadFile(v.files, path.Join(v.pathPrefix, environment+".recipients"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return publicKeys, nil
+		}
+
+		return nil, fmt.Errorf("read recipients file for %q environment: %s", environment, err)
+	}
+
+	recipients, err := ParseRecipients(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse recipients file for %q environment: %s", environment, err)
+	}
+
+	for i := range recipients {
+		publicKeys = append(publicKeys, recipients[i].PublicKey)
+	}
+
+	return publicKeys, nil
+}
+
+// checkEmbeddedKey fails if private key of the environment is embedded, but embedded keys are not allowed for it.
+func (v *vault) checkEmbeddedKey(environment string) error {
+	switch v.embeddedKeyPolicy {
+	case embeddedKeyAllowed:
+		return nil
+	case embeddedKeyDefault:
+		if environment == DefaultEnvironment {
+			return nil
+		}
+	}
+
+	_, err := fs.Stat(v.files, path.Join(v.pathPrefix, environment+".private"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("check embedded private key for %q environment: %w", environment, err)
+	}
+
+	return fmt.Errorf("%w for %q environment", ErrEmbeddedPrivateKey, environment)
+}
+
+// providePrivateKey asks key providers of the chain, in order, for private key of the environment.
+func (v *vault) providePrivateKey(environment string) ([]byte, error) {
+	providers := v.keyProviders
+	if providers == nil {
+		providers = v.defaultKeyProviders()
+	}
+
+	files, err := fs.Sub(v.files, v.pathPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("open %q directory: %s", v.pathPrefix, err)
+	}
+
+	for i := range providers {
+		key, err := providers[i].PrivateKey(files, environment)
+		if errors.Is(err, ErrNoKey) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("provide private key for %q environment: %w", environment, err)
+		}
+
+		return bytes.TrimSpace(key), nil
+	}
+
+	return nil, fmt.Errorf("private key for %q environment: %w", environment, ErrNoKey)
+}
+
+// defaultKeyProviders returns chain of key providers used unless KeyProviders option is given.
+func (v *vault) defaultKeyProviders() []KeyProvider {
+	providers := []KeyProvider{KeyProviderFunc(v.envPrivateKey), KeyProviderFunc(v.envPrivateKeyFile)}
+	for i := range v.keyFiles {
+		providers = append(providers, FileKey(v.keyFiles[i]))
+	}
+
+	return append(providers, CredentialsKey(), DockerSecretKey(), EmbeddedKey())
+}
+
+// envPrivateKeyFile provides private key from file pointed by environment variable
+// configured with EnvVariable option and suffixed with _FILE, e.g. UNTOLD_KEY_FILE.
+func (v *vault) envPrivateKeyFile(_ fs.FS, environment string) ([]byte, error) {
+	keyFile := os.Getenv(v.privateKeyEnvFor(environment) + "_FILE")
+	if keyFile == "" {
+		return nil, ErrNoKey
+	}
+
+	return os.ReadFile(keyFile)
+}
+
+// envPrivateKey provides private key from environment variable configured with EnvVariable option.
+func (v *vault) envPrivateKey(_ fs.FS, environment string) ([]byte, error) {
+	return EnvKey(v.privateKeyEnvFor(environment)).PrivateKey(nil, environment)
+}
+
+// passphraseFor returns function providing passphrase of encrypted private key of the environment.
+// Passphrase given with Passphrase option takes precedence over environment variable, e.g. UNTOLD_KEY_PASSPHRASE.
+func (v *vault) passphraseFor(environment string) PassphraseFunc {
+	return func() ([]byte, error) {
+		if v.passphrase != nil {
+			return v.passphrase, nil
+		}
+
+		passphrase := os.Getenv(v.privateKeyEnvFor(environment) + "_PASSPHRASE")
+		if passphrase == "" {
+			return nil, fmt.Errorf("key is encrypted, provide passphrase with Passphrase option or %s environment variable", v.privateKeyEnvFor(environment)+"_PASSPHRASE")
+		}
+
+		return []byte(passphrase), nil
+	}
 }
 
-func (v *vault) findSecret(name string) (string, error) {
-	md5Hash := md5.Sum([]byte(name))
+// privateKeyEnvFor returns name of environment variable holding private key for the environment.
+// Fallback environments use variable suffixed with environment name, e.g. UNTOLD_KEY_SHARED.
+func (v *vault) privateKeyEnvFor(environment string) string {
+	if environment == v.environment {
+		return v.privateKeyEnv
+	}
+
+	return v.privateKeyEnv + "_" + strings.ToUpper(nonAlphanumeric.ReplaceAllString(environment, "_"))
+}
+
+// findSecret searches for secret in every environment of the chain and returns the first one found together
+// with the environment it was found or failed to open in. Missing secret is reported with every searched environment.
+func (v *vault) findSecret(name string) ([]byte, string, error) {
+	environments := v.environments()
+
+	for _, environment := range environments {
+		value, err := v.findEnvironmentSecret(environment, name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
 
-	base64EncodedSecret, err := v.embeddedFiles.ReadFile(filepath.Join(v.pathPrefix, v.environment, hex.EncodeToString(md5Hash[:])))
+		return value, environment, err
+	}
+
+	if len(environments) == 1 {
+		return nil, v.environment, fmt.Errorf("secret %q for %q environment %w", name, v.environment, ErrNotFound)
+	}
+
+	return nil, strings.Join(environments, ", "), fmt.Errorf("secret %q for %s environments %w", name, quoteList(environments), ErrNotFound)
+}
+
+func (v *vault) findEnvironmentSecret(environment, name string) ([]byte, error) {
+	state, err := v.loadEnvironment(environment)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return "", fmt.Errorf("secret %q for %q environment not found", name, v.environment)
+		return nil, err
+	}
+
+	base64EncodedSecret, err := v.readEnvironmentFile(state, SecretFileName(name, state.nameKey))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
 		}
 
-		return "", fmt.Errorf("get secret for %q for %q environment: %s", name, v.environment, err)
+		return nil, fmt.Errorf("get secret for %q for %q environment: %s", name, environment, err)
+	}
+
+	decrypted, err := OpenSecret(base64EncodedSecret, Binding{Name: name, Environment: environment}, state.keys)
+	if errors.Is(err, ErrDecrypt) {
+		return nil, fmt.Errorf("%w secret %q for %q environment", ErrDecrypt, name, environment)
 	}
 
-	decodedSecret, err := Base64Decode(base64EncodedSecret)
 	if err != nil {
-		return "", fmt.Errorf("base64 decode %q for %q environment: %s", name, v.environment, err)
+		return nil, fmt.Errorf("open secret %q for %q environment: %w", name, environment, err)
 	}
 
-	decrypted, ok := box.OpenAnonymous(nil, decodedSecret, &v.publicKey, &v.privateKey)
-	if !ok {
-		return "", fmt.Errorf("can not decrypt secret %q for %q environment", name, v.environment)
+	return decrypted, nil
+}
+
+// quoteList formats values as comma separated list of quoted strings.
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i := range values {
+		quoted[i] = strconv.Quote(values[i])
 	}
 
-	return string(decrypted), nil
+	return strings.Join(quoted, ", ")
 }
@@ -2,14 +2,53 @@ package untold
 
 import (
 	"embed"
+	"errors"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 )
 
 //go:embed test
-var fs embed.FS
+var testFS embed.FS
+
+// testFiles copies named files of the test fixture to MapFS.
+func testFiles(t *testing.T, names ...string) fstest.MapFS {
+	t.Helper()
+
+	files := fstest.MapFS{}
+	for _, name := range names {
+		content, err := testFS.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[name] = &fstest.MapFile{Data: content}
+	}
+
+	return files
+}
+
+// testPublicKey returns public key of the test fixture.
+func testPublicKey(t *testing.T) [32]byte {
+	t.Helper()
+
+	content, err := testFS.ReadFile("test/test.public")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := DecodeBase64Key(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return publicKey
+}
 
 func TestFindSecret(t *testing.T) {
-	v := (NewVault(fs, Environment("test"), PathPrefix("test"))).(*vault)
+	v := (NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey())).(*vault)
 
 	if err := v.loadKeys(); err != nil {
 		t.Fatal(err)
@@ -27,31 +66,303 @@ func TestFindSecret(t *testing.T) {
 	}
 
 	for i := range tests {
-		value, err := v.findSecret(tests[i].input)
+		value, _, err := v.findSecret(tests[i].input)
 		if err != nil && err.Error() != tests[i].err {
 			t.Errorf("expected error to be %v, got %v", tests[i].input, err)
 		}
 
-		if value != tests[i].output {
+		if string(value) != tests[i].output {
 			t.Errorf("expected to get %q, got %q", tests[i].output, value)
 		}
 	}
 }
 
+func TestGet(t *testing.T) {
+	v := NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey())
+
+	value, err := v.Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "test" {
+		t.Errorf("expected %q, got %q", "test", value)
+	}
+
+	bytesValue, err := v.GetBytes("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(bytesValue) != "test" {
+		t.Errorf("expected %q, got %q", "test", bytesValue)
+	}
+
+	if _, err := v.Get("doesnt_exist"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	v := NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey())
+
+	value, ok, err := v.Lookup("test")
+	if err != nil || !ok || value != "test" {
+		t.Errorf("expected (%q, true, nil), got (%q, %t, %v)", "test", value, ok, err)
+	}
+
+	value, ok, err = v.Lookup("doesnt_exist")
+	if err != nil || ok || value != "" {
+		t.Errorf("expected (\"\", false, nil), got (%q, %t, %v)", value, ok, err)
+	}
+}
+
+func TestConcurrentGet(t *testing.T) {
+	v := NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := v.Get("test"); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if err := v.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.Get("test"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestGetDecryptError(t *testing.T) {
+	files := testFiles(t, "test/test.public", "test/test.private")
+
+	files["test/test/098f6bcd4621d373cade4e832627b4f6"] = &fstest.MapFile{Data: Base64Encode([]byte("not a ciphertext"))}
+
+	if _, err := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey()).Get("test"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt, got %v", err)
+	}
+}
+
 func TestLoadNotExistingKeys(t *testing.T) {
-	v := (NewVault(fs, Environment("not_existing"), PathPrefix("test"))).(*vault)
+	v := (NewVault(testFS, Environment("not_existing"), PathPrefix("test"), AllowEmbeddedPrivateKey())).(*vault)
 
 	err := v.loadKeys()
-	if err.Error() != "read public key file for \"not_existing\" environment: open test/not_existing.public: file does not exist" {
+	if err.Error() != "private key for \"not_existing\" environment: key not provided" {
 		t.Errorf("unexpected error %q", err.Error())
 	}
 }
 
 func TestLoadKeysBadPrefix(t *testing.T) {
-	v := (NewVault(fs, Environment("test"), PathPrefix("doesnt_exist"))).(*vault)
+	v := (NewVault(testFS, Environment("test"), PathPrefix("doesnt_exist"), AllowEmbeddedPrivateKey())).(*vault)
 
 	err := v.loadKeys()
-	if err.Error() != "read public key file for \"test\" environment: open doesnt_exist/test.public: file does not exist" {
+	if err.Error() != "private key for \"test\" environment: key not provided" {
 		t.Errorf("unexpected error %q", err.Error())
 	}
 }
+
+func TestLoadFromMapFS(t *testing.T) {
+	files := fstest.MapFS{}
+	for name, file := range testFiles(t, "test/test.public", "test/test.private", "test/test/098f6bcd4621d373cade4e832627b4f6") {
+		files["secrets/"+strings.TrimPrefix(name, "test/")] = file
+	}
+
+	var config struct {
+		Value string `untold:"test"`
+	}
+
+	if err := NewVault(files, Environment("test"), PathPrefix("secrets"), AllowEmbeddedPrivateKey()).Load(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Value != "test" {
+		t.Errorf("expected %q, got %q", "test", config.Value)
+	}
+}
+
+func TestLoadFromDirFS(t *testing.T) {
+	var config struct {
+		Value string `untold:"test"`
+	}
+
+	if err := NewVault(os.DirFS("test"), Environment("test"), PathPrefix("."), AllowEmbeddedPrivateKey()).Load(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Value != "test" {
+		t.Errorf("expected %q, got %q", "test", config.Value)
+	}
+}
+
+func TestLoadErrorEnvironment(t *testing.T) {
+	var config struct {
+		Value   string `untold:"test"`
+		Missing string `untold:"doesnt_exist"`
+	}
+
+	err := NewVault(testFS, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey()).Load(&config)
+	expected := `load secrets: field "Missing", secret "doesnt_exist", environment "test": secret "doesnt_exist" for "test" environment not found`
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	source := testFiles(t, "test/test.public", "test/test.private", "test/test/098f6bcd4621d373cade4e832627b4f6")
+
+	files := fstest.MapFS{"test/shared/098f6bcd4621d373cade4e832627b4f6": source["test/test/098f6bcd4621d373cade4e832627b4f6"]}
+	for _, environment := range []string{"staging", "shared"} {
+		for _, extension := range []string{".public", ".private"} {
+			files["test/"+environment+extension] = source["test/test"+extension]
+		}
+	}
+
+	v := NewVault(files, Environment("staging"), PathPrefix("test"), AllowEmbeddedPrivateKey(), Fallback("shared"))
+
+	value, err := v.Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "test" {
+		t.Errorf("expected %q, got %q", "test", value)
+	}
+
+	_, err = v.Get("doesnt_exist")
+	expected := `secret "doesnt_exist" for "staging", "shared" environments not found`
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+
+	files["test/shared/"+SecretFileName("broken", nil)] = &fstest.MapFile{Data: Base64Encode([]byte("not a ciphertext"))}
+
+	var config struct {
+		Broken  string `untold:"broken"`
+		Missing string `untold:"doesnt_exist"`
+	}
+
+	var loadErr *LoadError
+	if err := v.Load(&config); !errors.As(err, &loadErr) || len(loadErr.Errors) != 2 {
+		t.Fatalf("expected two field errors, got %v", err)
+	}
+
+	if environment := loadErr.Errors[0].Environment; environment != "shared" {
+		t.Errorf("expected decrypt error in %q environment, got %q", "shared", environment)
+	}
+
+	if environment := loadErr.Errors[1].Environment; environment != "staging, shared" {
+		t.Errorf("expected missing secret in %q environments, got %q", "staging, shared", environment)
+	}
+}
+
+func TestFallbackKeyLoadedOnDemand(t *testing.T) {
+	files := testFiles(t, "test/test.public", "test/test.private", "test/test/098f6bcd4621d373cade4e832627b4f6")
+
+	v := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey(), Fallback("shared"))
+
+	value, err := v.Get("test")
+	if err != nil {
+		t.Fatalf("expected secret of main environment without key of fallback environment, got %v", err)
+	}
+
+	if value != "test" {
+		t.Errorf("expected %q, got %q", "test", value)
+	}
+
+	if _, err := v.Get("doesnt_exist"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("expected ErrNoKey of fallback environment, got %v", err)
+	}
+}
+
+func TestDerivePublicKey(t *testing.T) {
+	files := testFiles(t, "test/test.private", "test/test/098f6bcd4621d373cade4e832627b4f6")
+
+	value, err := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey()).Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "test" {
+		t.Errorf("expected %q, got %q", "test", value)
+	}
+}
+
+func TestKeyMismatch(t *testing.T) {
+	files := testFiles(t, "test/test.private", "test/test/098f6bcd4621d373cade4e832627b4f6")
+
+	var otherKey [32]byte
+	otherKey[0] = 1
+	files["test/test.public"] = &fstest.MapFile{Data: Base64Encode(otherKey[:])}
+
+	_, err := NewVault(files, Environment("test"), PathPrefix("test"), AllowEmbeddedPrivateKey()).Get("test")
+	if !errors.Is(err, ErrKeyMismatch) {
+		t.Fatalf("expected ErrKeyMismatch, got %v", err)
+	}
+
+	expected := `private key does not match public key for environment "test"`
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err)
+	}
+}
+
+func TestEmbeddedPrivateKey(t *testing.T) {
+	privateKey, err := testFS.ReadFile("test/test.private")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("UNTOLD_TEST_EMBEDDED_KEY", string(privateKey))
+	defer os.Unsetenv("UNTOLD_TEST_EMBEDDED_KEY")
+
+	type test struct {
+		environment string
+		options     []Option
+		err         error
+	}
+
+	tests := []test{
+		{environment: "production", err: ErrEmbeddedPrivateKey},
+		{environment: "production", options: []Option{AllowEmbeddedPrivateKey()}},
+		{environment: DefaultEnvironment},
+		{environment: DefaultEnvironment, options: []Option{DisallowEmbeddedPrivateKey()}, err: ErrEmbeddedPrivateKey},
+	}
+
+	for i := range tests {
+		secret, err := testFS.ReadFile("test/test/098f6bcd4621d373cade4e832627b4f6")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files := fstest.MapFS{
+			"untold/" + tests[i].environment + ".private":                          &fstest.MapFile{Data: privateKey},
+			"untold/" + tests[i].environment + "/098f6bcd4621d373cade4e832627b4f6": &fstest.MapFile{Data: secret},
+		}
+
+		options := append([]Option{Environment(tests[i].environment), EnvVariable("UNTOLD_TEST_EMBEDDED_KEY")}, tests[i].options...)
+
+		_, err = NewVault(files, options...).Get("test")
+		if tests[i].err == nil && err != nil {
+			t.Errorf("%s: unexpected error %v", tests[i].environment, err)
+		}
+
+		if tests[i].err != nil && !errors.Is(err, tests[i].err) {
+			t.Errorf("%s: expected error %v, got %v", tests[i].environment, tests[i].err, err)
+		}
+	}
+}